@@ -2,14 +2,19 @@ package bundler
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"runtime"
+	"slices"
 
 	apko_build "chainguard.dev/apko/pkg/build"
 	apko_oci "chainguard.dev/apko/pkg/build/oci"
 	apko_types "chainguard.dev/apko/pkg/build/types"
 	"chainguard.dev/apko/pkg/tarfs"
+	"github.com/chainguard-dev/terraform-provider-imagetest/internal/log"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 )
@@ -20,6 +25,9 @@ type apko struct {
 	arch       apko_types.Architecture
 	apkoConfig apko_types.ImageConfiguration
 	ropts      []remote.Option
+	// cache reuses a previously built base image, tagged in the target repo
+	// by its cache key, instead of rebuilding it.
+	cache bool
 }
 
 type ApkoOpt func(*apko) error
@@ -79,6 +87,89 @@ func NewApko(opts ...ApkoOpt) (Bundler, error) {
 }
 
 func (a *apko) Bundle(ctx context.Context, repo name.Repository, layers ...Layerer) (name.Reference, error) {
+	base, err := a.base(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := appendLayers(base, layers...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to append layers: %w", err)
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get digest: %w", err)
+	}
+
+	ref := repo.Digest(digest.String())
+
+	if err := remote.Push(ref, img, a.ropts...); err != nil {
+		return nil, fmt.Errorf("failed to push bundle: %w", err)
+	}
+
+	return ref, nil
+}
+
+// base returns the apko built base image. When caching is enabled, a
+// previously built image with the same cache key is reused if it exists.
+func (a *apko) base(ctx context.Context, repo name.Repository) (v1.Image, error) {
+	if !a.cache {
+		return a.build(ctx)
+	}
+
+	key, err := a.cacheKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute cache key: %w", err)
+	}
+	tag := repo.Tag("apko-" + key)
+
+	if img, err := remote.Image(tag, a.ropts...); err == nil {
+		log.Info(ctx, "reusing cached harness base image", "tag", tag.String())
+		return img, nil
+	}
+
+	img, err := a.build(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := remote.Write(tag, img, a.ropts...); err != nil {
+		return nil, fmt.Errorf("failed to push cached base image: %w", err)
+	}
+
+	return img, nil
+}
+
+// cacheKey is a stable hash of the inputs to the base image build. The
+// order of packages, repositories, and keyrings does not affect the key.
+func (a *apko) cacheKey() (string, error) {
+	sorted := func(s []string) []string {
+		s = slices.Clone(s)
+		slices.Sort(s)
+		return slices.Compact(s)
+	}
+
+	data, err := json.Marshal(struct {
+		Arch         string   `json:"arch"`
+		Packages     []string `json:"packages"`
+		Repositories []string `json:"repositories"`
+		Keyrings     []string `json:"keyrings"`
+	}{
+		Arch:         a.arch.String(),
+		Packages:     sorted(a.apkoConfig.Contents.Packages),
+		Repositories: sorted(a.apkoConfig.Contents.RuntimeRepositories),
+		Keyrings:     sorted(a.apkoConfig.Contents.Keyring),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// build the base image with apko.
+func (a *apko) build(ctx context.Context) (v1.Image, error) {
 	bopts := []apko_build.Option{
 		apko_build.WithImageConfiguration(a.apkoConfig),
 		apko_build.WithArch(a.arch),
@@ -105,23 +196,7 @@ func (a *apko) Bundle(ctx context.Context, repo name.Repository, layers ...Layer
 		return nil, fmt.Errorf("failed to build image: %w", err)
 	}
 
-	img, err := appendLayers(base, layers...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to append layers: %w", err)
-	}
-
-	digest, err := img.Digest()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get digest: %w", err)
-	}
-
-	ref := repo.Digest(digest.String())
-
-	if err := remote.Push(ref, img, a.ropts...); err != nil {
-		return nil, fmt.Errorf("failed to push bundle: %w", err)
-	}
-
-	return ref, nil
+	return base, nil
 }
 
 func ApkoWithPackages(packages ...string) ApkoOpt {
@@ -152,6 +227,15 @@ func ApkoWithRemoteOptions(opts ...remote.Option) ApkoOpt {
 	}
 }
 
+// ApkoWithCache enables reusing a previously built base image when the
+// packages, repositories, keyrings, and architecture are unchanged.
+func ApkoWithCache(enabled bool) ApkoOpt {
+	return func(a *apko) error {
+		a.cache = enabled
+		return nil
+	}
+}
+
 func ApkoWithArch(arch string) ApkoOpt {
 	return func(a *apko) error {
 		a.arch = apko_types.ParseArchitecture(arch)
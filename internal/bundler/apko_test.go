@@ -0,0 +1,61 @@
+package bundler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApkoCacheKey(t *testing.T) {
+	key := func(opts ...ApkoOpt) string {
+		t.Helper()
+		b, err := NewApko(append([]ApkoOpt{ApkoWithArch("amd64")}, opts...)...)
+		require.NoError(t, err)
+		k, err := b.(*apko).cacheKey()
+		require.NoError(t, err)
+		return k
+	}
+
+	base := key(
+		ApkoWithPackages("docker", "docker-dind"),
+		ApkoWithRepositories("https://example.com/repo"),
+		ApkoWithKeyrings("https://example.com/key.rsa.pub"),
+	)
+
+	// Stable across calls
+	require.Equal(t, base, key(
+		ApkoWithPackages("docker", "docker-dind"),
+		ApkoWithRepositories("https://example.com/repo"),
+		ApkoWithKeyrings("https://example.com/key.rsa.pub"),
+	))
+
+	// Order and duplicates don't matter
+	require.Equal(t, base, key(
+		ApkoWithKeyrings("https://example.com/key.rsa.pub"),
+		ApkoWithPackages("docker-dind"),
+		ApkoWithRepositories("https://example.com/repo"),
+		ApkoWithPackages("docker", "docker"),
+	))
+
+	// Any change in the inputs changes the key
+	require.NotEqual(t, base, key(
+		ApkoWithPackages("docker", "docker-dind", "jq"),
+		ApkoWithRepositories("https://example.com/repo"),
+		ApkoWithKeyrings("https://example.com/key.rsa.pub"),
+	))
+	require.NotEqual(t, base, key(
+		ApkoWithPackages("docker", "docker-dind"),
+		ApkoWithRepositories("https://example.com/other"),
+		ApkoWithKeyrings("https://example.com/key.rsa.pub"),
+	))
+	require.NotEqual(t, base, key(
+		ApkoWithPackages("docker", "docker-dind"),
+		ApkoWithRepositories("https://example.com/repo"),
+	))
+	require.NotEqual(t, base, key(
+		ApkoWithArch("arm64"),
+		ApkoWithPackages("docker", "docker-dind"),
+		ApkoWithRepositories("https://example.com/repo"),
+		ApkoWithKeyrings("https://example.com/key.rsa.pub"),
+	))
+}
@@ -15,6 +15,7 @@ import (
 // copying the base to the target repo.
 type appender struct {
 	base  name.Reference
+	desc  *remote.Descriptor
 	ropts []remote.Option
 }
 
@@ -35,9 +36,13 @@ func NewAppender(base name.Reference, opts ...AppenderOpt) (Bundler, error) {
 }
 
 func (a *appender) Bundle(ctx context.Context, repo name.Repository, layers ...Layerer) (name.Reference, error) {
-	desc, err := remote.Get(a.base, a.ropts...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get image: %w", err)
+	desc := a.desc
+	if desc == nil {
+		var err error
+		desc, err = remote.Get(a.base, a.ropts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get image: %w", err)
+		}
 	}
 
 	if desc.MediaType.IsIndex() {
@@ -108,7 +113,7 @@ func (a *appender) Bundle(ctx context.Context, repo name.Repository, layers ...L
 		return ref, nil
 
 	} else if desc.MediaType.IsImage() {
-		baseimg, err := remote.Image(a.base, a.ropts...)
+		baseimg, err := desc.Image()
 		if err != nil {
 			return nil, fmt.Errorf("failed to get image: %w", err)
 		}
@@ -142,6 +147,15 @@ func (a *appender) Bundle(ctx context.Context, repo name.Repository, layers ...L
 	return nil, fmt.Errorf("unsupported media type: %s", desc.MediaType)
 }
 
+// AppenderWithDescriptor uses an already resolved descriptor for the base
+// image instead of fetching it from the registry.
+func AppenderWithDescriptor(desc *remote.Descriptor) AppenderOpt {
+	return func(a *appender) error {
+		a.desc = desc
+		return nil
+	}
+}
+
 func AppenderWithRemoteOptions(opts ...remote.Option) AppenderOpt {
 	return func(a *appender) error {
 		a.ropts = append(a.ropts, opts...)
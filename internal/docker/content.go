@@ -2,6 +2,7 @@ package docker
 
 import (
 	"archive/tar"
+	"fmt"
 	"io"
 	"os"
 	"path"
@@ -104,3 +105,120 @@ func (c *Content) stream() {
 		return
 	}
 }
+
+// ExtractTar extracts the tar stream r into the local directory dst, creating
+// it if it does not exist. Entries that would escape dst are rejected, either
+// directly or through a symlink, so untrusted archives can't write outside
+// dst.
+func ExtractTar(r io.Reader, dst string) error {
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return fmt.Errorf("creating destination directory: %w", err)
+	}
+
+	root, err := filepath.Abs(dst)
+	if err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("reading tar: %w", err)
+		}
+
+		target := filepath.Join(root, filepath.FromSlash(hdr.Name))
+		if !within(root, target) {
+			return fmt.Errorf("tar entry %s escapes destination %s", hdr.Name, dst)
+		}
+
+		// Never write through a symlink, since an earlier entry could have
+		// pointed it anywhere
+		if err := noSymlinks(root, target); err != nil {
+			return fmt.Errorf("tar entry %s: %w", hdr.Name, err)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, hdr.FileInfo().Mode().Perm()|0700); err != nil {
+				return fmt.Errorf("creating directory %s: %w", target, err)
+			}
+
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Errorf("creating directory %s: %w", filepath.Dir(target), err)
+			}
+
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, hdr.FileInfo().Mode().Perm())
+			if err != nil {
+				return fmt.Errorf("creating file %s: %w", target, err)
+			}
+
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return fmt.Errorf("writing file %s: %w", target, err)
+			}
+
+			if err := f.Close(); err != nil {
+				return err
+			}
+
+		case tar.TypeSymlink:
+			link := hdr.Linkname
+			if !filepath.IsAbs(link) {
+				link = filepath.Join(filepath.Dir(target), filepath.FromSlash(link))
+			}
+			if !within(root, link) {
+				return fmt.Errorf("tar entry %s links to %s outside destination %s", hdr.Name, hdr.Linkname, dst)
+			}
+
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Errorf("creating directory %s: %w", filepath.Dir(target), err)
+			}
+
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return fmt.Errorf("creating symlink %s: %w", target, err)
+			}
+
+		default:
+			// Skip anything else (devices, fifos, hardlinks, etc...)
+			continue
+		}
+	}
+}
+
+// within reports whether path is root or inside it. Both must be clean.
+func within(root, path string) bool {
+	return path == root || strings.HasPrefix(path, root+string(filepath.Separator))
+}
+
+// noSymlinks returns an error if target, or any of its parents below root, is
+// a symlink.
+func noSymlinks(root, target string) error {
+	rel, err := filepath.Rel(root, target)
+	if err != nil {
+		return err
+	}
+	if rel == "." {
+		return nil
+	}
+
+	cur := root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		cur = filepath.Join(cur, part)
+		fi, err := os.Lstat(cur)
+		if os.IsNotExist(err) {
+			// Nothing further down exists yet
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("refusing to write through symlink %s", cur)
+		}
+	}
+
+	return nil
+}
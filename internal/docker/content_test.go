@@ -37,6 +37,103 @@ func TestContentFromFile(t *testing.T) {
 	checkContent(t, c, "/test.txt", "Hello World")
 }
 
+func TestExtractTar(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+
+	entries := []struct {
+		hdr  *tar.Header
+		data string
+	}{
+		{hdr: &tar.Header{Name: "out/", Mode: 0755, Typeflag: tar.TypeDir}},
+		{hdr: &tar.Header{Name: "out/a.txt", Mode: 0644, Typeflag: tar.TypeReg}, data: "a"},
+		{hdr: &tar.Header{Name: "out/nested/", Mode: 0755, Typeflag: tar.TypeDir}},
+		{hdr: &tar.Header{Name: "out/nested/b.txt", Mode: 0600, Typeflag: tar.TypeReg}, data: "bb"},
+		{hdr: &tar.Header{Name: "out/link", Linkname: "a.txt", Typeflag: tar.TypeSymlink}},
+	}
+	for _, e := range entries {
+		e.hdr.Size = int64(len(e.data))
+		require.NoError(t, tw.WriteHeader(e.hdr))
+		_, err := tw.Write([]byte(e.data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	dst := t.TempDir()
+	require.NoError(t, ExtractTar(&buf, dst))
+
+	data, err := os.ReadFile(path.Join(dst, "out", "a.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "a", string(data))
+
+	data, err = os.ReadFile(path.Join(dst, "out", "nested", "b.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "bb", string(data))
+
+	info, err := os.Stat(path.Join(dst, "out", "nested", "b.txt"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	link, err := os.Readlink(path.Join(dst, "out", "link"))
+	require.NoError(t, err)
+	require.Equal(t, "a.txt", link)
+}
+
+func TestExtractTarEscape(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "../evil", Mode: 0644, Typeflag: tar.TypeReg}))
+	require.NoError(t, tw.Close())
+
+	err := ExtractTar(&buf, t.TempDir())
+	require.ErrorContains(t, err, "escapes destination")
+}
+
+func TestExtractTarSymlinkEscape(t *testing.T) {
+	for name, entries := range map[string][]*tar.Header{
+		"absolute link": {
+			{Name: "out", Linkname: "/tmp", Typeflag: tar.TypeSymlink},
+		},
+		"relative link": {
+			{Name: "out/link", Linkname: "../../evil", Typeflag: tar.TypeSymlink},
+		},
+		"write through link": {
+			// The link itself stays within the destination, but is replaced
+			// by a directory in the container
+			{Name: "out/dir/", Mode: 0755, Typeflag: tar.TypeDir},
+			{Name: "out/link", Linkname: "dir", Typeflag: tar.TypeSymlink},
+			{Name: "out/link/file", Mode: 0644, Typeflag: tar.TypeReg},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			for _, hdr := range entries {
+				require.NoError(t, tw.WriteHeader(hdr))
+			}
+			require.NoError(t, tw.Close())
+
+			dst := t.TempDir()
+			require.Error(t, ExtractTar(&buf, dst))
+		})
+	}
+
+	// A link out of the destination followed by a file written through it
+	outside := t.TempDir()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "out", Linkname: outside, Typeflag: tar.TypeSymlink}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "out/evil", Mode: 0644, Size: 4, Typeflag: tar.TypeReg}))
+	_, err := tw.Write([]byte("evil"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	require.Error(t, ExtractTar(&buf, t.TempDir()))
+	_, err = os.Stat(path.Join(outside, "evil"))
+	require.True(t, os.IsNotExist(err))
+}
+
 func checkContent(t *testing.T, c *Content, wantTarget string, wantContent string) {
 	var buf bytes.Buffer
 	_, err := io.Copy(&buf, c)
@@ -9,7 +9,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -30,8 +33,18 @@ import (
 )
 
 type Client struct {
-	cli   *client.Client
-	copts []client.Opt
+	cli      *client.Client
+	copts    []client.Opt
+	keychain authn.Keychain
+	// labels are stamped on every resource created by the client, in addition
+	// to any per request labels.
+	labels map[string]string
+	// stopTimeout is how long containers are given to exit after SIGTERM
+	// before being killed when stopped or removed.
+	stopTimeout time.Duration
+	// workingDir is the working directory of commands run in the client's
+	// containers that don't set one.
+	workingDir string
 }
 
 type Request struct {
@@ -54,6 +67,148 @@ type Request struct {
 	AutoRemove   bool
 	Logger       io.Writer
 	Init         bool
+	// ReadOnlyRootfs mounts the container's root filesystem as read only.
+	// Writable paths can still be provided with tmpfs Mounts.
+	ReadOnlyRootfs bool
+	// RestartPolicy defaults to never restarting the container.
+	RestartPolicy container.RestartPolicy
+	// RegistryAuth is a set of pre-resolved credentials keyed by registry
+	// host. When the image's registry is present, it is used to pull the
+	// image instead of resolving credentials from the keychain.
+	RegistryAuth map[string]registry.AuthConfig
+	// PullPolicy determines when the image is pulled. Defaults to
+	// PullPolicyIfNotPresent.
+	PullPolicy PullPolicy
+	// DeviceRequests exposes host devices, such as GPUs, to the container.
+	DeviceRequests []container.DeviceRequest
+	// Devices maps host devices, such as block devices, into the container.
+	Devices []container.DeviceMapping
+	// CgroupParent places the container under the given cgroup, such as for
+	// resource accounting of nested containers. With the systemd cgroup
+	// driver this must be a slice (foo.slice), and with the cgroupfs driver a
+	// path relative to the cgroup root. On cgroup v2 hosts, the parent must
+	// delegate the controllers the container uses, or resource limits fail to
+	// apply.
+	CgroupParent string
+}
+
+type PullPolicy string
+
+const (
+	// PullPolicyIfNotPresent only pulls the image when it is not already
+	// present in the daemon.
+	PullPolicyIfNotPresent PullPolicy = "if-not-present"
+	// PullPolicyAlways pulls the image on every start, picking up changes to
+	// moving tags.
+	PullPolicyAlways PullPolicy = "always"
+	// PullPolicyNever never pulls the image, and fails if it is not already
+	// present in the daemon.
+	PullPolicyNever PullPolicy = "never"
+)
+
+// GPURequest returns a device request for the host's nvidia GPUs. gpus is
+// either "all" or the number of GPUs to request.
+func GPURequest(gpus string) (container.DeviceRequest, error) {
+	count := -1
+	if gpus != "all" {
+		n, err := strconv.Atoi(gpus)
+		if err != nil || n < 1 {
+			return container.DeviceRequest{}, fmt.Errorf("invalid gpus %q: must be \"all\" or a positive number", gpus)
+		}
+		count = n
+	}
+
+	return container.DeviceRequest{
+		Driver:       "nvidia",
+		Count:        count,
+		Capabilities: [][]string{{"gpu"}},
+	}, nil
+}
+
+// PublishPort returns port bindings that publish the container's tcp port on
+// a fixed port on the host's loopback interface, for when tests need a
+// deterministic host port rather than one picked by the daemon. It fails if
+// the host port is already in use, which is only meaningful when the daemon
+// runs on the same host.
+func PublishPort(containerPort, hostPort int) (nat.PortMap, error) {
+	if hostPort < 1 || hostPort > 65535 {
+		return nil, fmt.Errorf("invalid host port %d", hostPort)
+	}
+
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(hostPort))
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("host port %d is not available: %w", hostPort, err)
+	}
+	if err := l.Close(); err != nil {
+		return nil, fmt.Errorf("releasing host port %d: %w", hostPort, err)
+	}
+
+	port, err := nat.NewPort("tcp", strconv.Itoa(containerPort))
+	if err != nil {
+		return nil, fmt.Errorf("invalid container port %d: %w", containerPort, err)
+	}
+
+	return nat.PortMap{
+		port: []nat.PortBinding{
+			{
+				HostIP:   "127.0.0.1",
+				HostPort: strconv.Itoa(hostPort),
+			},
+		},
+	}, nil
+}
+
+// ParseDevice parses a device mapping in the docker cli's
+// src[:dst][:perms] format, where perms is a combination of r (read),
+// w (write) and m (mknod). dst defaults to src, and perms to rwm.
+func ParseDevice(spec string) (container.DeviceMapping, error) {
+	dm := container.DeviceMapping{
+		CgroupPermissions: "rwm",
+	}
+
+	parts := strings.Split(spec, ":")
+	switch len(parts) {
+	case 3:
+		dm.PathOnHost, dm.PathInContainer, dm.CgroupPermissions = parts[0], parts[1], parts[2]
+	case 2:
+		dm.PathOnHost = parts[0]
+		if validDevicePermissions(parts[1]) {
+			dm.CgroupPermissions = parts[1]
+		} else {
+			dm.PathInContainer = parts[1]
+		}
+	case 1:
+		dm.PathOnHost = parts[0]
+	default:
+		return container.DeviceMapping{}, fmt.Errorf("invalid device %q: must be src[:dst][:perms]", spec)
+	}
+
+	if dm.PathInContainer == "" {
+		dm.PathInContainer = dm.PathOnHost
+	}
+
+	if !filepath.IsAbs(dm.PathOnHost) || !filepath.IsAbs(dm.PathInContainer) {
+		return container.DeviceMapping{}, fmt.Errorf("invalid device %q: paths must be absolute", spec)
+	}
+
+	if !validDevicePermissions(dm.CgroupPermissions) {
+		return container.DeviceMapping{}, fmt.Errorf("invalid device %q: permissions must be a combination of r, w and m", spec)
+	}
+
+	return dm, nil
+}
+
+func validDevicePermissions(perms string) bool {
+	if perms == "" || len(perms) > 3 {
+		return false
+	}
+	for _, c := range perms {
+		if !strings.ContainsRune("rwm", c) || strings.Count(perms, string(c)) > 1 {
+			return false
+		}
+	}
+	return true
 }
 
 type ResourcesRequest struct {
@@ -66,7 +221,9 @@ type ResourcesRequest struct {
 
 func New(opts ...Option) (*Client, error) {
 	d := &Client{
-		copts: make([]client.Opt, 0),
+		copts:       make([]client.Opt, 0),
+		keychain:    authn.DefaultKeychain,
+		stopTimeout: 5 * time.Second,
 	}
 
 	for _, opt := range opts {
@@ -198,6 +355,7 @@ func (d *Client) Start(ctx context.Context, req *Request) (*Response, error) {
 		ID:            cid,
 		Name:          cname,
 		cli:           d.cli,
+		workingDir:    d.workingDir,
 	}, nil
 }
 
@@ -228,13 +386,21 @@ func (d *Client) start(ctx context.Context, req *Request) (string, error) {
 		req.PortBindings = make(nat.PortMap)
 	}
 
+	if req.PullPolicy == "" {
+		req.PullPolicy = PullPolicyIfNotPresent
+	}
+
+	if req.RestartPolicy.Name == "" {
+		// Never restart
+		req.RestartPolicy.Name = container.RestartPolicyDisabled
+	}
+
 	exposedPorts := make(nat.PortSet)
 	for port := range req.PortBindings {
 		exposedPorts[port] = struct{}{}
 	}
 
-	// Pull the image if it doesn't already exist
-	if err := d.pull(ctx, req.Ref); err != nil {
+	if err := d.pull(ctx, req); err != nil {
 		return "", fmt.Errorf("pulling image: %w", err)
 	}
 
@@ -252,21 +418,22 @@ func (d *Client) start(ctx context.Context, req *Request) (string, error) {
 			ExposedPorts: exposedPorts,
 		},
 		&container.HostConfig{
-			ExtraHosts: req.ExtraHosts,
-			Privileged: req.Privileged,
-			RestartPolicy: container.RestartPolicy{
-				// Never restart
-				Name: container.RestartPolicyDisabled,
-			},
+			ExtraHosts:    req.ExtraHosts,
+			Privileged:    req.Privileged,
+			RestartPolicy: req.RestartPolicy,
 			Resources: container.Resources{
 				Memory:            req.Resources.MemoryLimit.Value(),
 				MemoryReservation: req.Resources.MemoryRequest.Value(),
 				NanoCPUs:          req.Resources.CpuRequest.Value(),
+				DeviceRequests:    req.DeviceRequests,
+				Devices:           req.Devices,
+				CgroupParent:      req.CgroupParent,
 			},
-			Mounts:       req.Mounts,
-			PortBindings: req.PortBindings,
-			AutoRemove:   req.AutoRemove,
-			Init:         &req.Init,
+			Mounts:         req.Mounts,
+			PortBindings:   req.PortBindings,
+			AutoRemove:     req.AutoRemove,
+			Init:           &req.Init,
+			ReadonlyRootfs: req.ReadOnlyRootfs,
 		},
 		&network.NetworkingConfig{
 			EndpointsConfig: endpointSettings,
@@ -305,36 +472,43 @@ func (d *Client) Connect(ctx context.Context, cid string) (*Response, error) {
 	}
 
 	return &Response{
-		ID:   info.ID,
-		Name: info.Name,
-		cli:  d.cli,
+		ID:         info.ID,
+		Name:       info.Name,
+		cli:        d.cli,
+		workingDir: d.workingDir,
 	}, nil
 }
 
-// pull the image if it doesn't exist in the daemon.
-func (d *Client) pull(ctx context.Context, ref name.Reference) error {
-	if _, _, err := d.cli.ImageInspectWithRaw(ctx, ref.Name()); err != nil {
-		if !client.IsErrNotFound(err) {
-			return fmt.Errorf("checking if image exists: %w", err)
+// pull the image according to the request's pull policy. When the request
+// has a logger, pull progress is reported to it.
+func (d *Client) pull(ctx context.Context, req *Request) error {
+	ref, policy := req.Ref, req.PullPolicy
+
+	switch policy {
+	case PullPolicyAlways:
+	case PullPolicyIfNotPresent, PullPolicyNever:
+		present := true
+		if _, _, err := d.cli.ImageInspectWithRaw(ctx, ref.Name()); err != nil {
+			if !client.IsErrNotFound(err) {
+				return fmt.Errorf("checking if image exists: %w", err)
+			}
+			present = false
 		}
-	}
 
-	// create our own auth token... why this isn't handled by the client is
-	// beyond me
-	a, err := authn.DefaultKeychain.Resolve(ref.Context().Registry)
-	if err != nil {
-		return fmt.Errorf("resolving keychain for registry %s: %w", ref.Context().Registry, err)
-	}
+		if present {
+			return nil
+		}
 
-	acfg, err := a.Authorization()
-	if err != nil {
-		return fmt.Errorf("getting authorization for registry %s: %w", ref.Context().Registry, err)
+		if policy == PullPolicyNever {
+			return fmt.Errorf("image %s is not present and pull policy is %q", ref.Name(), policy)
+		}
+	default:
+		return fmt.Errorf("unknown pull policy %q", policy)
 	}
 
-	auth := registry.AuthConfig{
-		Username: acfg.Username,
-		Password: acfg.Password,
-		Auth:     acfg.Auth,
+	auth, err := d.auth(ref, req.RegistryAuth)
+	if err != nil {
+		return err
 	}
 
 	authdata, err := json.Marshal(auth)
@@ -348,6 +522,11 @@ func (d *Client) pull(ctx context.Context, ref name.Reference) error {
 	if err != nil {
 		return err
 	}
+	defer pull.Close()
+
+	if req.Logger != nil {
+		return reportPullProgress(pull, req.Logger, ref.Name(), pullProgressInterval)
+	}
 
 	// Block until the image is pulled by discarding the reader
 	if _, err := io.Copy(io.Discard, pull); err != nil {
@@ -357,14 +536,49 @@ func (d *Client) pull(ctx context.Context, ref name.Reference) error {
 	return nil
 }
 
-// Remove forcibly removes all the resources associated with the given request.
-func (d *Client) Remove(ctx context.Context, resp *Response) error {
-	force := 0
+// Stop stops the container without removing it, giving it the client's stop
+// timeout to exit gracefully.
+func (d *Client) Stop(ctx context.Context, resp *Response) error {
+	timeout := int(d.stopTimeout.Seconds())
 	if err := d.cli.ContainerStop(ctx, resp.ID, container.StopOptions{
-		Timeout: &force,
+		Timeout: &timeout,
 	}); err != nil {
 		return fmt.Errorf("stopping container: %w", err)
 	}
+	return nil
+}
+
+// auth returns the registry auth used to pull ref. Pre-resolved auths take
+// precedence over the client's keychain.
+func (d *Client) auth(ref name.Reference, auths map[string]registry.AuthConfig) (registry.AuthConfig, error) {
+	if a, ok := auths[ref.Context().RegistryStr()]; ok {
+		return a, nil
+	}
+
+	// create our own auth token... why this isn't handled by the client is
+	// beyond me
+	a, err := d.keychain.Resolve(ref.Context().Registry)
+	if err != nil {
+		return registry.AuthConfig{}, fmt.Errorf("resolving keychain for registry %s: %w", ref.Context().Registry, err)
+	}
+
+	acfg, err := a.Authorization()
+	if err != nil {
+		return registry.AuthConfig{}, fmt.Errorf("getting authorization for registry %s: %w", ref.Context().Registry, err)
+	}
+
+	return registry.AuthConfig{
+		Username: acfg.Username,
+		Password: acfg.Password,
+		Auth:     acfg.Auth,
+	}, nil
+}
+
+// Remove forcibly removes all the resources associated with the given request.
+func (d *Client) Remove(ctx context.Context, resp *Response) error {
+	if err := d.Stop(ctx, resp); err != nil {
+		return err
+	}
 
 	return d.cli.ContainerRemove(ctx, resp.ID, container.RemoveOptions{
 		RemoveVolumes: true,
@@ -377,75 +591,126 @@ type Response struct {
 	ID   string
 	Name string
 	cli  *client.Client
+	// workingDir is used for commands that don't set their own.
+	workingDir string
 }
 
 func (r *Response) Run(ctx context.Context, cmd harness.Command) error {
+	var stdall bytes.Buffer
+	cmd.Stdout = teeWriter(&stdall, cmd.Stdout)
+	cmd.Stderr = teeWriter(&stdall, cmd.Stderr)
+
+	code, err := r.exec(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	if code != 0 {
+		return &harness.RunError{
+			ExitCode:       code,
+			CombinedOutput: stdall.String(),
+			Cmd:            cmd.Args,
+		}
+	}
+
+	return nil
+}
+
+// Exec runs the command in the container and returns its captured stdout,
+// stderr, and exit code. Unlike Run, a non-zero exit code is not an error;
+// err is reserved for failures to execute the command at all.
+func (r *Response) Exec(ctx context.Context, cmd harness.Command) (string, string, int, error) {
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = teeWriter(&stdout, cmd.Stdout)
+	cmd.Stderr = teeWriter(&stderr, cmd.Stderr)
+
+	code, err := r.exec(ctx, cmd)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	return stdout.String(), stderr.String(), code, nil
+}
+
+// exec runs the command in the container, streaming its output to the
+// command's writers, and returns the exit code.
+func (r *Response) exec(ctx context.Context, cmd harness.Command) (int, error) {
+	wd := cmd.WorkingDir
+	if wd == "" {
+		wd = r.workingDir
+	}
+
 	resp, err := r.cli.ContainerExecCreate(ctx, r.ID, container.ExecOptions{
 		Cmd:          []string{"sh", "-c", cmd.Args},
-		WorkingDir:   cmd.WorkingDir,
+		WorkingDir:   wd,
+		User:         cmd.User,
+		Env:          execEnv(cmd.Env),
 		AttachStderr: true,
 		AttachStdout: true,
 	})
 	if err != nil {
-		return fmt.Errorf("creating exec: %w", err)
+		return 0, fmt.Errorf("creating exec: %w", err)
 	}
 
 	if resp.ID == "" {
-		return fmt.Errorf("exec ID is empty")
+		return 0, fmt.Errorf("exec ID is empty")
 	}
 
 	attach, err := r.cli.ContainerExecAttach(ctx, resp.ID, container.ExecStartOptions{})
 	if err != nil {
-		return fmt.Errorf("attaching to exec: %w", err)
+		return 0, fmt.Errorf("attaching to exec: %w", err)
 	}
 	defer attach.Close()
 
 	if err := r.cli.ContainerExecStart(ctx, resp.ID, container.ExecStartOptions{}); err != nil {
-		return fmt.Errorf("starting exec: %w", err)
-	}
-
-	var stdout, stderr, stdall bytes.Buffer
-	var stdoutw, stderrw, stdallw io.Writer
-	stdoutw, stderrw, stdallw = &stdout, &stderr, &stdall
-
-	if cmd.Stdout != nil {
-		stdoutw = io.MultiWriter(stdoutw, stdallw, cmd.Stdout)
-	}
-
-	if cmd.Stderr != nil {
-		stderrw = io.MultiWriter(stderrw, stdallw, cmd.Stderr)
+		return 0, fmt.Errorf("starting exec: %w", err)
 	}
 
 	done := make(chan error, 1)
 
 	go func() {
-		_, err := stdcopy.StdCopy(stdoutw, stderrw, attach.Reader)
+		_, err := stdcopy.StdCopy(cmd.Stdout, cmd.Stderr, attach.Reader)
 		done <- err
 	}()
 
 	select {
 	case <-ctx.Done():
-		return fmt.Errorf("context cancelled while waiting for command to finish: %w", ctx.Err())
+		return 0, fmt.Errorf("context cancelled while waiting for command to finish: %w", ctx.Err())
 	case err := <-done:
 		if err != nil {
-			return fmt.Errorf("command exited with error: %w", err)
+			return 0, fmt.Errorf("command exited with error: %w", err)
 		}
 	}
 
 	exec, err := r.cli.ContainerExecInspect(ctx, resp.ID)
 	if err != nil {
-		return fmt.Errorf("inspecting exec: %w", err)
+		return 0, fmt.Errorf("inspecting exec: %w", err)
 	}
 
-	if exec.ExitCode != 0 {
-		return &harness.RunError{
-			ExitCode:       exec.ExitCode,
-			CombinedOutput: stdall.String(),
-			Cmd:            cmd.Args,
-		}
+	return exec.ExitCode, nil
+}
+
+// execEnv converts the command's environment into the KEY=VALUE form used by
+// execs, sorted so the resulting request is deterministic.
+func execEnv(env map[string]string) []string {
+	if len(env) == 0 {
+		return nil
 	}
 
-	return nil
+	out := make([]string, 0, len(env))
+	for k, v := range env {
+		out = append(out, k+"="+v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// teeWriter returns a writer that writes to buf and, when set, w.
+func teeWriter(buf *bytes.Buffer, w io.Writer) io.Writer {
+	if w == nil {
+		return buf
+	}
+	return io.MultiWriter(buf, w)
 }
 
 func (r *Response) GetFile(ctx context.Context, path string) (io.Reader, error) {
@@ -479,16 +744,38 @@ func (r *Response) GetFile(ctx context.Context, path string) (io.Reader, error)
 	return tr, nil
 }
 
+// GetDir returns the raw tar stream for the given path in the container, which
+// may be a directory. The caller is responsible for closing the returned
+// stream. ExtractTar can be used to write the stream to a local directory.
+func (r *Response) GetDir(ctx context.Context, path string) (io.ReadCloser, error) {
+	// ensure path is absolute
+	if !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("path %s is not absolute", path)
+	}
+
+	trc, _, err := r.cli.CopyFromContainer(ctx, r.ID, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return trc, nil
+}
+
+// withDefaultLabels merges the given labels on top of the default labels and
+// any client level labels. The given labels take precedence.
 func (d *Client) withDefaultLabels(labels map[string]string) map[string]string {
 	l := map[string]string{
 		"dev.chainguard.imagetest": "true",
+		SessionLabel:               session,
 	}
 
-	for k, v := range l {
-		if _, ok := labels[k]; !ok {
-			labels[k] = v
-		}
+	for k, v := range d.labels {
+		l[k] = v
+	}
+
+	for k, v := range labels {
+		l[k] = v
 	}
 
-	return labels
+	return l
 }
@@ -2,12 +2,28 @@ package docker
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/chainguard-dev/terraform-provider-imagetest/internal/harness"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/stretchr/testify/require"
 )
@@ -71,6 +87,34 @@ func TestDocker(t *testing.T) {
 	err = resp.Run(ctx, harness.Command{Args: "exit 1"})
 	require.ErrorContains(t, err, "exit code 1")
 
+	// Exec captures stdout and stderr separately
+	stdout, stderr, code, err := resp.Exec(ctx, harness.Command{Args: "echo out; echo err >&2"})
+	require.NoError(t, err)
+	require.Equal(t, 0, code)
+	require.Equal(t, "out\n", stdout)
+	require.Equal(t, "err\n", stderr)
+
+	// A non-zero exit is reported through the exit code, not the error
+	stdout, stderr, code, err = resp.Exec(ctx, harness.Command{Args: "echo failing >&2; exit 3"})
+	require.NoError(t, err)
+	require.Equal(t, 3, code)
+	require.Empty(t, stdout)
+	require.Equal(t, "failing\n", stderr)
+
+	// Commands can run as a different user than the container
+	stdout, _, code, err = resp.Exec(ctx, harness.Command{Args: "id -u", User: "65532"})
+	require.NoError(t, err)
+	require.Equal(t, 0, code)
+	require.Equal(t, "65532\n", stdout)
+
+	// Command environment variables are visible to the command
+	stdout, _, _, err = resp.Exec(ctx, harness.Command{
+		Args: "echo $FOO $BAR",
+		Env:  map[string]string{"FOO": "foo", "BAR": "bar baz"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "foo bar baz\n", stdout)
+
 	// Ensure the files were created
 	err = resp.Run(ctx, harness.Command{Args: "cat /test | grep test1"})
 	require.NoError(t, err)
@@ -95,6 +139,22 @@ func TestDocker(t *testing.T) {
 	_, err = resp.GetFile(ctx, "test")
 	require.ErrorContains(t, err, "not absolute")
 
+	// Get a directory from the container
+	err = resp.Run(ctx, harness.Command{Args: "mkdir -p /out/nested && echo a > /out/a && echo b > /out/nested/b"})
+	require.NoError(t, err)
+
+	drc, err := resp.GetDir(ctx, "/out")
+	require.NoError(t, err)
+
+	dst := t.TempDir()
+	err = ExtractTar(drc, dst)
+	require.NoError(t, err)
+	require.NoError(t, drc.Close())
+
+	data, err = os.ReadFile(filepath.Join(dst, "out", "nested", "b"))
+	require.NoError(t, err)
+	require.Equal(t, "b\n", string(data))
+
 	// Cleanup
 	err = d.Remove(ctx, resp)
 	require.NoError(t, err)
@@ -109,3 +169,625 @@ func TestDocker(t *testing.T) {
 	err = d.RemoveNetwork(ctx, nw)
 	require.NoError(t, err)
 }
+
+func TestDockerReadOnlyRootfs(t *testing.T) {
+	ctx := context.Background()
+
+	d, fd := newFakeDaemon(t)
+
+	_, err := d.start(ctx, &Request{
+		Ref:            name.MustParseReference("cgr.dev/chainguard/wolfi-base:latest"),
+		ReadOnlyRootfs: true,
+		Mounts: []mount.Mount{
+			{Type: mount.TypeTmpfs, Target: "/tmp"},
+		},
+	})
+	require.NoError(t, err)
+
+	require.NotNil(t, fd.create.HostConfig)
+	require.True(t, fd.create.HostConfig.ReadonlyRootfs)
+	require.Len(t, fd.create.HostConfig.Mounts, 1)
+	require.Equal(t, mount.TypeTmpfs, fd.create.HostConfig.Mounts[0].Type)
+}
+
+func TestDockerRestartPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	d, fd := newFakeDaemon(t)
+
+	// Default to never restarting
+	_, err := d.start(ctx, &Request{
+		Ref: name.MustParseReference("cgr.dev/chainguard/wolfi-base:latest"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, container.RestartPolicyDisabled, fd.create.HostConfig.RestartPolicy.Name)
+
+	_, err = d.start(ctx, &Request{
+		Ref: name.MustParseReference("cgr.dev/chainguard/wolfi-base:latest"),
+		RestartPolicy: container.RestartPolicy{
+			Name:              container.RestartPolicyOnFailure,
+			MaximumRetryCount: 3,
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, container.RestartPolicyOnFailure, fd.create.HostConfig.RestartPolicy.Name)
+	require.Equal(t, 3, fd.create.HostConfig.RestartPolicy.MaximumRetryCount)
+}
+
+func TestDockerDefaultLabels(t *testing.T) {
+	ctx := context.Background()
+
+	d, fd := newFakeDaemon(t)
+	require.NoError(t, WithDefaultLabels(map[string]string{
+		"ci.run-id": "1234",
+		"ci.job":    "default",
+	})(d))
+
+	_, err := d.start(ctx, &Request{
+		Ref: name.MustParseReference("cgr.dev/chainguard/wolfi-base:latest"),
+		Labels: map[string]string{
+			"ci.job": "override",
+			"foo":    "bar",
+		},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, map[string]string{
+		"dev.chainguard.imagetest": "true",
+		SessionLabel:               session,
+		"ci.run-id":                "1234",
+		"ci.job":                   "override",
+		"foo":                      "bar",
+	}, fd.create.Labels)
+}
+
+func TestDockerHealthCheck(t *testing.T) {
+	ctx := context.Background()
+
+	d, fd := newFakeDaemon(t)
+	fd.present = true
+
+	req := &Request{
+		Ref:     name.MustParseReference("cgr.dev/chainguard/wolfi-base:latest"),
+		Timeout: 30 * time.Second,
+		HealthCheck: &container.HealthConfig{
+			Test: []string{"CMD", "docker", "info"},
+		},
+	}
+
+	// Slow starting containers can be reported unhealthy before they become
+	// healthy, so keep waiting
+	fd.healths = []*types.Health{
+		{Status: "starting"},
+		{Status: "unhealthy"},
+		{Status: "healthy"},
+	}
+	_, err := d.Start(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, []string{"CMD", "docker", "info"}, fd.create.Healthcheck.Test)
+
+	// A container that never becomes healthy times out
+	fd.healths = []*types.Health{{Status: "unhealthy"}}
+	req.Timeout = 2 * time.Second
+	_, err = d.Start(ctx, req)
+	require.ErrorContains(t, err, "waiting for container to be running")
+}
+
+func TestDockerRegistryAuth(t *testing.T) {
+	ctx := context.Background()
+
+	d, fd := newFakeDaemon(t)
+	require.NoError(t, WithKeychain(staticKeychain{
+		"registry.example.com": authn.AuthConfig{Username: "keychain", Password: "keychain"},
+	})(d))
+
+	// Without pre-resolved auth, the keychain is used
+	_, err := d.start(ctx, &Request{
+		Ref: name.MustParseReference("registry.example.com/foo:latest"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "keychain", fd.pullAuth.Username)
+
+	// Pre-resolved auth for the image's registry takes precedence
+	_, err = d.start(ctx, &Request{
+		Ref: name.MustParseReference("registry.example.com/foo:latest"),
+		RegistryAuth: map[string]registry.AuthConfig{
+			"registry.example.com": {Username: "static", Password: "static"},
+			"other.example.com":    {Username: "other", Password: "other"},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "static", fd.pullAuth.Username)
+	require.Equal(t, "static", fd.pullAuth.Password)
+}
+
+func TestDockerPullPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    PullPolicy
+		present   bool
+		wantPulls int
+		wantErr   bool
+	}{
+		{name: "default absent", present: false, wantPulls: 1},
+		{name: "default present", present: true, wantPulls: 0},
+		{name: "if-not-present absent", policy: PullPolicyIfNotPresent, present: false, wantPulls: 1},
+		{name: "if-not-present present", policy: PullPolicyIfNotPresent, present: true, wantPulls: 0},
+		{name: "always absent", policy: PullPolicyAlways, present: false, wantPulls: 1},
+		{name: "always present", policy: PullPolicyAlways, present: true, wantPulls: 1},
+		{name: "never present", policy: PullPolicyNever, present: true, wantPulls: 0},
+		{name: "never absent", policy: PullPolicyNever, present: false, wantPulls: 0, wantErr: true},
+		{name: "unknown", policy: PullPolicy("sometimes"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, fd := newFakeDaemon(t)
+			fd.present = tt.present
+
+			_, err := d.start(context.Background(), &Request{
+				Ref:        name.MustParseReference("cgr.dev/chainguard/wolfi-base:latest"),
+				PullPolicy: tt.policy,
+			})
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantPulls, fd.pulls)
+		})
+	}
+}
+
+func TestDockerDeviceRequests(t *testing.T) {
+	ctx := context.Background()
+
+	all, err := GPURequest("all")
+	require.NoError(t, err)
+	require.Equal(t, container.DeviceRequest{Driver: "nvidia", Count: -1, Capabilities: [][]string{{"gpu"}}}, all)
+
+	two, err := GPURequest("2")
+	require.NoError(t, err)
+	require.Equal(t, 2, two.Count)
+
+	for _, gpus := range []string{"", "some", "0", "-1"} {
+		_, err := GPURequest(gpus)
+		require.Error(t, err, gpus)
+	}
+
+	d, fd := newFakeDaemon(t)
+	_, err = d.start(ctx, &Request{
+		Ref: name.MustParseReference("cgr.dev/chainguard/wolfi-base:latest"),
+	})
+	require.NoError(t, err)
+	require.Empty(t, fd.create.HostConfig.DeviceRequests)
+
+	_, err = d.start(ctx, &Request{
+		Ref:            name.MustParseReference("cgr.dev/chainguard/wolfi-base:latest"),
+		DeviceRequests: []container.DeviceRequest{all},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []container.DeviceRequest{all}, fd.create.HostConfig.DeviceRequests)
+}
+
+func TestDockerDevices(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		spec string
+		want container.DeviceMapping
+	}{
+		{"/dev/sdb", container.DeviceMapping{PathOnHost: "/dev/sdb", PathInContainer: "/dev/sdb", CgroupPermissions: "rwm"}},
+		{"/dev/sdb:/dev/xvdb", container.DeviceMapping{PathOnHost: "/dev/sdb", PathInContainer: "/dev/xvdb", CgroupPermissions: "rwm"}},
+		{"/dev/sdb:r", container.DeviceMapping{PathOnHost: "/dev/sdb", PathInContainer: "/dev/sdb", CgroupPermissions: "r"}},
+		{"/dev/sdb:/dev/xvdb:rw", container.DeviceMapping{PathOnHost: "/dev/sdb", PathInContainer: "/dev/xvdb", CgroupPermissions: "rw"}},
+	} {
+		got, err := ParseDevice(tc.spec)
+		require.NoError(t, err, tc.spec)
+		require.Equal(t, tc.want, got, tc.spec)
+	}
+
+	for _, spec := range []string{"", "sdb", "/dev/sdb:xvdb", "/dev/sdb:/dev/xvdb:rx", "/dev/sdb:/dev/xvdb:rr", "/a:/b:r:w"} {
+		_, err := ParseDevice(spec)
+		require.Error(t, err, spec)
+	}
+
+	dm, err := ParseDevice("/dev/sdb:/dev/xvdb:rw")
+	require.NoError(t, err)
+
+	d, fd := newFakeDaemon(t)
+	_, err = d.start(ctx, &Request{
+		Ref:     name.MustParseReference("cgr.dev/chainguard/wolfi-base:latest"),
+		Devices: []container.DeviceMapping{dm},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []container.DeviceMapping{dm}, fd.create.HostConfig.Devices)
+}
+
+func TestDockerPublishPort(t *testing.T) {
+	ctx := context.Background()
+
+	// Find a free host port
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	hostPort := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+
+	ports, err := PublishPort(8080, hostPort)
+	require.NoError(t, err)
+
+	d, fd := newFakeDaemon(t)
+	_, err = d.start(ctx, &Request{
+		Ref:          name.MustParseReference("cgr.dev/chainguard/wolfi-base:latest"),
+		PortBindings: ports,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []nat.PortBinding{
+		{HostIP: "127.0.0.1", HostPort: strconv.Itoa(hostPort)},
+	}, fd.create.HostConfig.PortBindings["8080/tcp"])
+	require.Contains(t, fd.create.ExposedPorts, nat.Port("8080/tcp"))
+
+	// Ports that are already bound are rejected up front
+	l, err = net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(hostPort)))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+
+	_, err = PublishPort(8080, hostPort)
+	require.ErrorContains(t, err, "not available")
+
+	_, err = PublishPort(8080, 0)
+	require.Error(t, err)
+}
+
+func TestDockerPrune(t *testing.T) {
+	ctx := context.Background()
+
+	old := time.Now().Add(-2 * time.Hour)
+	recent := time.Now().Add(-time.Minute)
+
+	// Resources created by this process are never pruned, however old
+	ours := map[string]string{SessionLabel: session}
+
+	seed := func(fd *fakeDaemon) {
+		fd.containers = []map[string]any{
+			{"Id": "old-container", "Created": old.Unix()},
+			{"Id": "recent-container", "Created": recent.Unix()},
+			{"Id": "our-container", "Created": old.Unix(), "Labels": ours},
+		}
+		fd.networks = []map[string]any{
+			{"Id": "old-network-id", "Name": "old-network", "Created": old},
+			{"Id": "recent-network-id", "Name": "recent-network", "Created": recent},
+			{"Id": "our-network-id", "Name": "our-network", "Created": old, "Labels": ours},
+		}
+		fd.volumes = []map[string]any{
+			{"Name": "old-volume", "CreatedAt": old.Format(time.RFC3339)},
+			{"Name": "recent-volume", "CreatedAt": recent.Format(time.RFC3339)},
+			{"Name": "our-volume", "CreatedAt": old.Format(time.RFC3339), "Labels": ours},
+		}
+	}
+
+	d, fd := newFakeDaemon(t)
+	seed(fd)
+
+	report, err := d.Prune(ctx, &PruneRequest{OlderThan: time.Hour})
+	require.NoError(t, err)
+	require.Equal(t, &PruneReport{
+		Containers: []string{"old-container"},
+		Networks:   []string{"old-network"},
+		Volumes:    []string{"old-volume"},
+	}, report)
+	require.Contains(t, fd.listFilters, "dev.chainguard.imagetest=true")
+
+	require.Equal(t, []string{
+		"/containers/old-container",
+		"/networks/old-network-id",
+		"/volumes/old-volume",
+	}, fd.deleted)
+
+	// Without an age, everything is pruned
+	d, fd = newFakeDaemon(t)
+	seed(fd)
+
+	report, err = d.Prune(ctx, &PruneRequest{})
+	require.NoError(t, err)
+	require.Len(t, report.Containers, 2)
+	require.Len(t, report.Networks, 2)
+	require.Len(t, report.Volumes, 2)
+	require.Len(t, fd.deleted, 6)
+
+	// A dry run only reports what would be pruned
+	d, fd = newFakeDaemon(t)
+	seed(fd)
+
+	report, err = d.Prune(ctx, &PruneRequest{DryRun: true})
+	require.NoError(t, err)
+	require.Len(t, report.Containers, 2)
+	require.Empty(t, fd.deleted)
+
+	// A resource that can't be removed doesn't stop the others
+	d, fd = newFakeDaemon(t)
+	seed(fd)
+	fd.conflicts = map[string]bool{"/networks/old-network-id": true}
+
+	report, err = d.Prune(ctx, &PruneRequest{OlderThan: time.Hour})
+	require.ErrorContains(t, err, "old-network")
+	require.Equal(t, &PruneReport{
+		Containers: []string{"old-container"},
+		Volumes:    []string{"old-volume"},
+	}, report)
+	require.Equal(t, []string{
+		"/containers/old-container",
+		"/volumes/old-volume",
+	}, fd.deleted)
+}
+
+func TestDockerCgroupParent(t *testing.T) {
+	ctx := context.Background()
+
+	d, fd := newFakeDaemon(t)
+	_, err := d.start(ctx, &Request{
+		Ref: name.MustParseReference("cgr.dev/chainguard/wolfi-base:latest"),
+	})
+	require.NoError(t, err)
+	require.Empty(t, fd.create.HostConfig.CgroupParent)
+
+	_, err = d.start(ctx, &Request{
+		Ref:          name.MustParseReference("cgr.dev/chainguard/wolfi-base:latest"),
+		CgroupParent: "imagetest.slice",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "imagetest.slice", fd.create.HostConfig.CgroupParent)
+}
+
+func TestDockerStopTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	// Defaults to a graceful stop
+	d, fd := newFakeDaemon(t)
+	require.NoError(t, d.Remove(ctx, &Response{ID: "fake"}))
+	require.Equal(t, "5", fd.stopTimeout)
+	require.True(t, fd.removed)
+
+	d, fd = newFakeDaemon(t)
+	require.NoError(t, WithStopTimeout(30*time.Second)(d))
+	require.NoError(t, d.Stop(ctx, &Response{ID: "fake"}))
+	require.Equal(t, "30", fd.stopTimeout)
+	require.False(t, fd.removed)
+
+	// A zero timeout kills immediately
+	d, fd = newFakeDaemon(t)
+	require.NoError(t, WithStopTimeout(0)(d))
+	require.NoError(t, d.Remove(ctx, &Response{ID: "fake"}))
+	require.Equal(t, "0", fd.stopTimeout)
+
+	require.Error(t, WithStopTimeout(-time.Second)(d))
+}
+
+func TestDockerWorkingDir(t *testing.T) {
+	ctx := context.Background()
+
+	d, fd := newFakeDaemon(t)
+	require.NoError(t, WithWorkingDir("/work")(d))
+
+	resp, err := d.Connect(ctx, "fake")
+	require.NoError(t, err)
+
+	require.NoError(t, resp.Run(ctx, harness.Command{Args: "true"}))
+	require.Equal(t, "/work", fd.exec.WorkingDir)
+
+	require.NoError(t, resp.Run(ctx, harness.Command{Args: "true", WorkingDir: "/override"}))
+	require.Equal(t, "/override", fd.exec.WorkingDir)
+
+	// A failing command is still reported
+	fd.exitCode = 1
+	var rerr *harness.RunError
+	require.ErrorAs(t, resp.Run(ctx, harness.Command{Args: "false"}), &rerr)
+	require.Equal(t, 1, rerr.ExitCode)
+}
+
+func TestDockerExecUser(t *testing.T) {
+	ctx := context.Background()
+
+	d, fd := newFakeDaemon(t)
+	resp, err := d.Connect(ctx, "fake")
+	require.NoError(t, err)
+
+	require.NoError(t, resp.Run(ctx, harness.Command{Args: "true"}))
+	require.Empty(t, fd.exec.User)
+
+	require.NoError(t, resp.Run(ctx, harness.Command{Args: "true", User: "65532:65532"}))
+	require.Equal(t, "65532:65532", fd.exec.User)
+}
+
+func TestDockerExecEnv(t *testing.T) {
+	ctx := context.Background()
+
+	d, fd := newFakeDaemon(t)
+	resp, err := d.Connect(ctx, "fake")
+	require.NoError(t, err)
+
+	require.NoError(t, resp.Run(ctx, harness.Command{Args: "true"}))
+	require.Empty(t, fd.exec.Env)
+
+	require.NoError(t, resp.Run(ctx, harness.Command{
+		Args: "true",
+		Env:  map[string]string{"FOO": "foo", "BAR": "bar=baz"},
+	}))
+	require.Equal(t, []string{"BAR=bar=baz", "FOO=foo"}, fd.exec.Env)
+}
+
+type staticKeychain map[string]authn.AuthConfig
+
+func (k staticKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	if cfg, ok := k[target.RegistryStr()]; ok {
+		return authn.FromConfig(cfg), nil
+	}
+	return authn.Anonymous, nil
+}
+
+// fakeDaemon is a minimal stand in for the docker daemon API. It records the
+// requests made by the client so tests can assert on them without needing a
+// running daemon.
+type fakeDaemon struct {
+	create container.CreateRequest
+	// pullAuth is the decoded X-Registry-Auth of the last image pull
+	pullAuth registry.AuthConfig
+	// present reports images as already existing in the daemon
+	present bool
+	pulls   int
+	// stopTimeout is the timeout query of the last container stop
+	stopTimeout string
+	removed     bool
+	// exec is the last exec created in a container
+	exec container.ExecOptions
+	// exitCode is the exit code execs report
+	exitCode int
+	// containers, networks and volumes are returned when listing resources
+	containers []map[string]any
+	networks   []map[string]any
+	volumes    []map[string]any
+	// listFilters are the filters of the last list request
+	listFilters string
+	// deleted are the paths of every successful DELETE request
+	deleted []string
+	// conflicts are the DELETE paths that fail as still in use
+	conflicts map[string]bool
+	// healths are the health states reported by successive container
+	// inspects. The last one is reported indefinitely.
+	healths []*types.Health
+}
+
+func newFakeDaemon(t *testing.T) (*Client, *fakeDaemon) {
+	t.Helper()
+
+	fd := &fakeDaemon{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Strip the api version prefix (/v1.xx)
+		path := r.URL.Path
+		if parts := strings.SplitN(path, "/", 3); len(parts) == 3 && strings.HasPrefix(parts[1], "v1.") {
+			path = "/" + parts[2]
+		}
+
+		switch {
+		case r.Method == http.MethodPost && path == "/containers/create":
+			if err := json.NewDecoder(r.Body).Decode(&fd.create); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(container.CreateResponse{ID: "fake"})
+
+		case r.Method == http.MethodPost && strings.HasPrefix(path, "/containers/") && strings.HasSuffix(path, "/start"):
+			w.WriteHeader(http.StatusNoContent)
+
+		case r.Method == http.MethodPost && strings.HasSuffix(path, "/stop"):
+			fd.stopTimeout = r.URL.Query().Get("t")
+			w.WriteHeader(http.StatusNoContent)
+
+		case r.Method == http.MethodGet && path == "/containers/json":
+			fd.listFilters = r.URL.Query().Get("filters")
+			_ = json.NewEncoder(w).Encode(fd.containers)
+
+		case r.Method == http.MethodGet && path == "/networks":
+			fd.listFilters = r.URL.Query().Get("filters")
+			_ = json.NewEncoder(w).Encode(fd.networks)
+
+		case r.Method == http.MethodGet && path == "/volumes":
+			fd.listFilters = r.URL.Query().Get("filters")
+			_ = json.NewEncoder(w).Encode(map[string]any{"Volumes": fd.volumes})
+
+		case r.Method == http.MethodDelete && fd.conflicts[path]:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"message":"resource is in use"}`))
+
+		case r.Method == http.MethodDelete && (strings.HasPrefix(path, "/networks/") || strings.HasPrefix(path, "/volumes/")):
+			fd.deleted = append(fd.deleted, path)
+			w.WriteHeader(http.StatusNoContent)
+
+		case r.Method == http.MethodGet && strings.HasPrefix(path, "/containers/") && strings.HasSuffix(path, "/json"):
+			var health *types.Health
+			if len(fd.healths) > 0 {
+				health = fd.healths[0]
+				if len(fd.healths) > 1 {
+					fd.healths = fd.healths[1:]
+				}
+			}
+			_ = json.NewEncoder(w).Encode(types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{
+					ID:    "fake",
+					Name:  "/fake",
+					State: &types.ContainerState{Running: true, Health: health},
+				},
+			})
+
+		case r.Method == http.MethodPost && strings.HasPrefix(path, "/containers/") && strings.HasSuffix(path, "/exec"):
+			if err := json.NewDecoder(r.Body).Decode(&fd.exec); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(types.IDResponse{ID: "fake-exec"})
+
+		case r.Method == http.MethodPost && path == "/exec/fake-exec/start" && r.Header.Get("Upgrade") != "":
+			// Attaching hijacks the connection to stream the output. The
+			// fake exec has none, so the stream ends straight away.
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			_, _ = conn.Write([]byte("HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n"))
+			_ = conn.Close()
+
+		case r.Method == http.MethodPost && path == "/exec/fake-exec/start":
+			w.WriteHeader(http.StatusOK)
+
+		case r.Method == http.MethodGet && path == "/exec/fake-exec/json":
+			_ = json.NewEncoder(w).Encode(container.ExecInspect{ExecID: "fake-exec", ExitCode: fd.exitCode})
+
+		case r.Method == http.MethodDelete && strings.HasPrefix(path, "/containers/"):
+			fd.deleted = append(fd.deleted, path)
+			fd.removed = true
+			w.WriteHeader(http.StatusNoContent)
+
+		case r.Method == http.MethodPost && path == "/images/create":
+			if h := r.Header.Get(registry.AuthHeader); h != "" {
+				data, err := base64.URLEncoding.DecodeString(h)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				if err := json.Unmarshal(data, &fd.pullAuth); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			fd.pulls++
+			_, _ = w.Write([]byte("{}"))
+
+		case r.Method == http.MethodGet && strings.HasPrefix(path, "/images/") && strings.HasSuffix(path, "/json"):
+			if !fd.present {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"message":"No such image"}`))
+				return
+			}
+			_, _ = w.Write([]byte("{}"))
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	cli, err := client.NewClientWithOpts(
+		client.WithHost("tcp://"+srv.Listener.Addr().String()),
+		client.WithVersion("1.45"),
+	)
+	require.NoError(t, err)
+
+	d, err := New(WithClient(cli))
+	require.NoError(t, err)
+
+	return d, fd
+}
@@ -1,7 +1,11 @@
 package docker
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/docker/docker/client"
+	"github.com/google/go-containerregistry/pkg/authn"
 )
 
 type Option func(*Client) error
@@ -25,3 +29,52 @@ func WithClientOpts(opts ...client.Opt) Option {
 		return nil
 	}
 }
+
+// WithKeychain sets the keychain used to resolve registry credentials when
+// pulling images. Defaults to authn.DefaultKeychain.
+func WithKeychain(kc authn.Keychain) Option {
+	return func(d *Client) error {
+		if kc != nil {
+			d.keychain = kc
+		}
+		return nil
+	}
+}
+
+// WithDefaultLabels sets labels that are applied to every container, network
+// and volume created by the client, such as a CI run ID. Per request labels
+// take precedence over these.
+func WithDefaultLabels(labels map[string]string) Option {
+	return func(d *Client) error {
+		if d.labels == nil {
+			d.labels = make(map[string]string)
+		}
+		for k, v := range labels {
+			d.labels[k] = v
+		}
+		return nil
+	}
+}
+
+// WithStopTimeout sets how long containers are given to exit after SIGTERM
+// before being killed when they are stopped or removed. A zero timeout kills
+// containers immediately. Defaults to 5 seconds.
+func WithStopTimeout(timeout time.Duration) Option {
+	return func(d *Client) error {
+		if timeout < 0 {
+			return fmt.Errorf("stop timeout must not be negative: %s", timeout)
+		}
+		d.stopTimeout = timeout
+		return nil
+	}
+}
+
+// WithWorkingDir sets the default working directory for commands run in the
+// client's containers. Commands that set their own working directory take
+// precedence.
+func WithWorkingDir(dir string) Option {
+	return func(d *Client) error {
+		d.workingDir = dir
+		return nil
+	}
+}
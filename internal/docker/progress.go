@@ -0,0 +1,116 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+// pullProgressInterval is how often pull progress is reported.
+const pullProgressInterval = 5 * time.Second
+
+type layerProgress struct {
+	current int64
+	total   int64
+	done    bool
+}
+
+// reportPullProgress consumes the daemon's pull stream, periodically writing a
+// summary of the layer and byte progress to w, followed by a final summary
+// once the stream ends. Errors reported in the stream are returned.
+func reportPullProgress(r io.Reader, w io.Writer, image string, interval time.Duration) error {
+	layers := make(map[string]*layerProgress)
+	last := time.Now()
+
+	dec := json.NewDecoder(r)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := dec.Decode(&msg); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("decoding pull progress: %w", err)
+		}
+
+		if msg.Error != nil {
+			return fmt.Errorf("pulling image: %s", msg.Error.Message)
+		}
+
+		if msg.ID != "" {
+			trackLayer(layers, &msg)
+		}
+
+		if time.Since(last) >= interval {
+			writePullSummary(w, image, "pulling", layers)
+			last = time.Now()
+		}
+	}
+
+	writePullSummary(w, image, "pulled", layers)
+	return nil
+}
+
+// trackLayer updates the layer's progress from the message. Messages that
+// aren't about a layer (such as the "Pulling from" header) are ignored.
+func trackLayer(layers map[string]*layerProgress, msg *jsonmessage.JSONMessage) {
+	var l *layerProgress
+	switch msg.Status {
+	case "Pulling fs layer", "Waiting", "Downloading", "Verifying Checksum",
+		"Download complete", "Extracting", "Pull complete", "Already exists":
+		var ok bool
+		if l, ok = layers[msg.ID]; !ok {
+			l = &layerProgress{}
+			layers[msg.ID] = l
+		}
+	default:
+		return
+	}
+
+	switch msg.Status {
+	case "Downloading":
+		if msg.Progress != nil {
+			l.current = msg.Progress.Current
+			if msg.Progress.Total > 0 {
+				l.total = msg.Progress.Total
+			}
+		}
+	case "Download complete", "Extracting":
+		l.current = l.total
+	case "Pull complete", "Already exists":
+		l.current = l.total
+		l.done = true
+	}
+}
+
+func writePullSummary(w io.Writer, image, verb string, layers map[string]*layerProgress) {
+	var done int
+	var current, total int64
+	for _, l := range layers {
+		if l.done {
+			done++
+		}
+		current += l.current
+		total += l.total
+	}
+
+	fmt.Fprintf(w, "%s %s: %d/%d layers complete, %s/%s downloaded\n",
+		verb, image, done, len(layers), humanBytes(current), humanBytes(total))
+}
+
+// humanBytes formats n in binary units, e.g. 1.5MiB.
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
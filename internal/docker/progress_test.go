@@ -0,0 +1,57 @@
+package docker
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const cannedPull = `{"status":"Pulling from chainguard/wolfi-base","id":"latest"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"aaa"}
+{"status":"Already exists","progressDetail":{},"id":"bbb"}
+{"status":"Downloading","progressDetail":{"current":512,"total":2048},"progress":"[====>    ]","id":"aaa"}
+{"status":"Downloading","progressDetail":{"current":1536,"total":2048},"progress":"[=======> ]","id":"aaa"}
+{"status":"Download complete","progressDetail":{},"id":"aaa"}
+{"status":"Extracting","progressDetail":{"current":2048,"total":2048},"id":"aaa"}
+{"status":"Pull complete","progressDetail":{},"id":"aaa"}
+{"status":"Digest: sha256:deadbeef"}
+{"status":"Status: Downloaded newer image for cgr.dev/chainguard/wolfi-base:latest"}
+`
+
+func TestReportPullProgress(t *testing.T) {
+	var out bytes.Buffer
+	err := reportPullProgress(strings.NewReader(cannedPull), &out, "cgr.dev/chainguard/wolfi-base:latest", 0)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	// One periodic report per message, plus the final summary
+	require.Len(t, lines, 11)
+	require.Equal(t, "pulling cgr.dev/chainguard/wolfi-base:latest: 1/2 layers complete, 512B/2.0KiB downloaded", lines[3])
+	require.Equal(t, "pulled cgr.dev/chainguard/wolfi-base:latest: 2/2 layers complete, 2.0KiB/2.0KiB downloaded", lines[len(lines)-1])
+
+	// Without an interval elapsing, only the final summary is reported
+	out.Reset()
+	err = reportPullProgress(strings.NewReader(cannedPull), &out, "cgr.dev/chainguard/wolfi-base:latest", time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, "pulled cgr.dev/chainguard/wolfi-base:latest: 2/2 layers complete, 2.0KiB/2.0KiB downloaded\n", out.String())
+}
+
+func TestReportPullProgressError(t *testing.T) {
+	stream := `{"status":"Pulling from chainguard/wolfi-base","id":"latest"}
+{"errorDetail":{"message":"manifest unknown"},"error":"manifest unknown"}
+`
+	var out bytes.Buffer
+	err := reportPullProgress(strings.NewReader(stream), &out, "cgr.dev/chainguard/wolfi-base:latest", 0)
+	require.ErrorContains(t, err, "manifest unknown")
+}
+
+func TestHumanBytes(t *testing.T) {
+	require.Equal(t, "0B", humanBytes(0))
+	require.Equal(t, "1023B", humanBytes(1023))
+	require.Equal(t, "1.0KiB", humanBytes(1024))
+	require.Equal(t, "1.5MiB", humanBytes(1536*1024))
+	require.Equal(t, "2.0GiB", humanBytes(2<<30))
+}
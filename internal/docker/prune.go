@@ -0,0 +1,135 @@
+package docker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+)
+
+// SessionLabel is stamped on every resource with the ID of the process that
+// created it, so a prune never removes resources that are still in use by the
+// same process.
+const SessionLabel = "dev.chainguard.imagetest.session"
+
+// session is the ID of this process.
+var session = newSession()
+
+func newSession() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("generating session id: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// PruneRequest selects the imagetest resources to prune.
+type PruneRequest struct {
+	// OlderThan only prunes resources created at least this long ago, so
+	// resources of runs that are still in progress in other processes are left
+	// alone. Resources created by this process are never pruned.
+	OlderThan time.Duration
+	// DryRun reports what would be pruned without removing anything.
+	DryRun bool
+}
+
+// PruneReport lists the resources that were (or, for a dry run, would be)
+// pruned.
+type PruneReport struct {
+	Containers []string
+	Networks   []string
+	Volumes    []string
+}
+
+// Prune removes the dangling containers, networks and volumes created by
+// imagetest, as identified by the dev.chainguard.imagetest label. Containers
+// are removed first so the networks and volumes they use can be removed. A
+// resource that can't be removed doesn't stop the rest from being pruned, and
+// the report only lists the resources that were removed.
+func (d *Client) Prune(ctx context.Context, req *PruneRequest) (*PruneReport, error) {
+	args := filters.NewArgs(filters.Arg("label", "dev.chainguard.imagetest=true"))
+	cutoff := time.Now().Add(-req.OlderThan)
+	report := &PruneReport{}
+
+	var errs []error
+
+	containers, err := d.cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: args,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing containers: %w", err)
+	}
+
+	for _, c := range containers {
+		if c.Labels[SessionLabel] == session || time.Unix(c.Created, 0).After(cutoff) {
+			continue
+		}
+		if !req.DryRun {
+			if err := d.cli.ContainerRemove(ctx, c.ID, container.RemoveOptions{
+				Force:         true,
+				RemoveVolumes: true,
+			}); err != nil {
+				errs = append(errs, fmt.Errorf("removing container %s: %w", c.ID, err))
+				continue
+			}
+		}
+		report.Containers = append(report.Containers, c.ID)
+	}
+
+	networks, err := d.cli.NetworkList(ctx, network.ListOptions{
+		Filters: args,
+	})
+	if err != nil {
+		errs = append(errs, fmt.Errorf("listing networks: %w", err))
+	}
+
+	for _, nw := range networks {
+		if nw.Labels[SessionLabel] == session || nw.Created.After(cutoff) {
+			continue
+		}
+		if !req.DryRun {
+			if err := d.cli.NetworkRemove(ctx, nw.ID); err != nil {
+				errs = append(errs, fmt.Errorf("removing network %s: %w", nw.Name, err))
+				continue
+			}
+		}
+		report.Networks = append(report.Networks, nw.Name)
+	}
+
+	volumes, err := d.cli.VolumeList(ctx, volume.ListOptions{
+		Filters: args,
+	})
+	if err != nil {
+		errs = append(errs, fmt.Errorf("listing volumes: %w", err))
+	}
+
+	for _, v := range volumes.Volumes {
+		if v.Labels[SessionLabel] == session {
+			continue
+		}
+		// Volumes without a parseable creation time are treated as old
+		if created, err := time.Parse(time.RFC3339, v.CreatedAt); err == nil && created.After(cutoff) {
+			continue
+		}
+		if !req.DryRun {
+			if err := d.cli.VolumeRemove(ctx, v.Name, true); err != nil {
+				errs = append(errs, fmt.Errorf("removing volume %s: %w", v.Name, err))
+				continue
+			}
+		}
+		report.Volumes = append(report.Volumes, v.Name)
+	}
+
+	if len(errs) > 0 {
+		return report, fmt.Errorf("failed to prune resources: %v", errs)
+	}
+
+	return report, nil
+}
@@ -22,7 +22,7 @@ func (d *Client) CreateVolume(ctx context.Context, req *VolumeRequest) (mount.Mo
 
 	v, err := d.cli.VolumeCreate(ctx, volume.CreateOptions{
 		Name:   req.Name,
-		Labels: req.Labels,
+		Labels: d.withDefaultLabels(req.Labels),
 	})
 	if err != nil {
 		return mount.Mount{}, err
@@ -29,6 +29,13 @@ type StepOpt func(s *step)
 
 // StepWithRetry wraps the step in an exponential backoff retry loop.
 func StepWithRetry(backoff wait.Backoff) StepOpt {
+	return StepWithRetryIf(backoff, nil)
+}
+
+// StepWithRetryIf is like StepWithRetry, but only retries the step when
+// retryable returns true for the step's error. Errors that aren't retryable
+// fail the step immediately. A nil retryable retries on any error.
+func StepWithRetryIf(backoff wait.Backoff, retryable func(error) bool) StepOpt {
 	return func(s *step) {
 		of := s.Fn
 		s.Fn = func(ctx context.Context) error {
@@ -38,6 +45,10 @@ func StepWithRetry(backoff wait.Backoff) StepOpt {
 				attempts++
 				err := of(ctx)
 				if err != nil {
+					if retryable != nil && !retryable(err) {
+						log.Info(ctx, fmt.Sprintf("step failed attempt [%d/%d] with a non-retryable error", attempts, backoff.Steps), "name", s.Name, "error", err)
+						return false, err
+					}
 					log.Info(ctx, fmt.Sprintf("step failed attempt [%d/%d]", attempts, backoff.Steps), "name", s.Name, "error", err)
 					return false, nil
 				}
@@ -126,6 +126,33 @@ func TestFeature(t *testing.T) {
 			// This will always fail, so just grep on the error message
 			wanterr: "assessment step 'Assessment Step' failed:\ntimed out waiting for the condition",
 		},
+		{
+			name: "RetryNonRetryable",
+			befores: func(b *bytes.Buffer) []*step {
+				return []*step{}
+			},
+			assessments: func(b *bytes.Buffer) []*step {
+				terminal := errors.New("terminal error")
+				return []*step{
+					tstepWithRetryIf(&step{Name: "Assessment Step", Fn: func(ctx context.Context) error {
+						b.WriteString("foo ")
+						return terminal
+					}}, wait.Backoff{
+						Steps:    3,
+						Duration: 100 * time.Millisecond,
+						Factor:   1.0,
+					}, func(err error) bool {
+						return !errors.Is(err, terminal)
+					}),
+				}
+			},
+			afters: func(b *bytes.Buffer) []*step {
+				return []*step{}
+			},
+			// Only attempted once
+			wantout: "foo ",
+			wanterr: "assessment step 'Assessment Step' failed:\nterminal error",
+		},
 	}
 
 	for _, tt := range tests {
@@ -166,3 +193,8 @@ func tstepWithRetry(s *step, backoff wait.Backoff) *step {
 	StepWithRetry(backoff)(s)
 	return s
 }
+
+func tstepWithRetryIf(s *step, backoff wait.Backoff, retryable func(error) bool) *step {
+	StepWithRetryIf(backoff, retryable)(s)
+	return s
+}
@@ -5,18 +5,31 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
+	"runtime"
+	"time"
 
 	client "github.com/chainguard-dev/terraform-provider-imagetest/internal/docker"
 	"github.com/chainguard-dev/terraform-provider-imagetest/internal/harness"
+	"github.com/chainguard-dev/terraform-provider-imagetest/internal/log"
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	"k8s.io/apimachinery/pkg/api/resource"
 )
 
-var _ harness.Harness = &docker{}
+var (
+	_ harness.Harness           = &docker{}
+	_ harness.ArtifactCollector = &docker{}
+)
 
 const DefaultDockerSocketPath = "/var/run/docker.sock"
 
+// hostGatewayExtraHost resolves host.docker.internal to the host's gateway.
+const hostGatewayExtraHost = "host.docker.internal:host-gateway"
+
 type docker struct {
 	Name       string
 	ImageRef   name.Reference
@@ -26,9 +39,34 @@ type docker struct {
 	Envs       []string
 	Registries map[string]*RegistryConfig
 	Volumes    []VolumeConfig
+	// HostDockerInternal adds a host.docker.internal entry to the harness
+	// container that resolves to the host.
+	HostDockerInternal bool
+	// ExtraHosts are additional host:ip entries added to the harness
+	// container's /etc/hosts.
+	ExtraHosts    []string
+	RestartPolicy container.RestartPolicy
+	// AutoRemove removes the harness container on teardown. When disabled,
+	// the container is only stopped so it can be inspected after the run.
+	AutoRemove     bool
+	DeviceRequests []container.DeviceRequest
+	// User is the user (uid[:gid]) steps are run as, unless the command sets
+	// its own. When unset, steps run as the harness container's user.
+	User string
+	// HealthCheck is the harness container's health check. The harness is
+	// only ready once docker reports it healthy. When nil, the harness is
+	// ready as soon as the container is running.
+	HealthCheck *container.HealthConfig
+	// Artifacts are the container paths copied into ArtifactsDir on the host
+	// once all features have run, whether or not the harness is torn down.
+	Artifacts    []string
+	ArtifactsDir string
 
-	stack  *harness.Stack
-	runner func(context.Context, harness.Command) error
+	keychain authn.Keychain
+	stack    *harness.Stack
+	runner   func(context.Context, harness.Command) error
+	// container is the started harness container artifacts are copied from.
+	container artifactSource
 }
 
 func New(opts ...Option) (harness.Harness, error) {
@@ -41,7 +79,13 @@ func New(opts ...Option) (harness.Harness, error) {
 		Envs: []string{
 			"IMAGETEST=true",
 		},
-		stack: harness.NewStack(),
+		// Docker Desktop (macOS and Windows) already resolves
+		// host.docker.internal, so this is only needed on linux.
+		HostDockerInternal: runtime.GOOS == "linux",
+		AutoRemove:         true,
+		HealthCheck:        DefaultHealthCheck(),
+		keychain:           authn.DefaultKeychain,
+		stack:              harness.NewStack(),
 	}
 
 	for _, opt := range opts {
@@ -55,7 +99,7 @@ func New(opts ...Option) (harness.Harness, error) {
 
 // Create implements harness.Harness.
 func (h *docker) Create(ctx context.Context) error {
-	cli, err := client.New()
+	cli, err := client.New(client.WithKeychain(h.keychain))
 	if err != nil {
 		return err
 	}
@@ -76,6 +120,30 @@ func (h *docker) Create(ctx context.Context) error {
 		return fmt.Errorf("creating docker config json: %w", err)
 	}
 
+	resp, err := cli.Start(ctx, h.request(dockerconfigjson))
+	if err != nil {
+		return fmt.Errorf("starting container: %w", err)
+	}
+
+	if err := h.stack.Add(func(ctx context.Context) error {
+		if !h.AutoRemove {
+			return cli.Stop(ctx, resp)
+		}
+		return cli.Remove(ctx, resp)
+	}); err != nil {
+		return fmt.Errorf("adding container teardown to stack: %w", err)
+	}
+
+	h.container = resp
+	h.runner = func(ctx context.Context, cmd harness.Command) error {
+		return resp.Run(ctx, h.command(cmd))
+	}
+
+	return nil
+}
+
+// request builds the harness container request.
+func (h *docker) request(dockerconfigjson []byte) *client.Request {
 	mounts := append(h.Mounts, mount.Mount{
 		Type:   mount.TypeBind,
 		Source: "/var/run/docker.sock",
@@ -92,7 +160,7 @@ func (h *docker) Create(ctx context.Context) error {
 		}
 	}
 
-	resp, err := cli.Start(ctx, &client.Request{
+	return &client.Request{
 		Name:       h.Name,
 		Ref:        h.ImageRef,
 		Entrypoint: harness.DefaultEntrypoint(),
@@ -105,25 +173,32 @@ func (h *docker) Create(ctx context.Context) error {
 		Contents: []*client.Content{
 			client.NewContentFromString(string(dockerconfigjson), "/root/.docker/config.json"),
 		},
-		ExtraHosts: []string{
-			"host.docker.internal:host-gateway",
-		},
-	})
-	if err != nil {
-		return fmt.Errorf("starting container: %w", err)
+		ExtraHosts:     h.extraHosts(),
+		RestartPolicy:  h.RestartPolicy,
+		RegistryAuth:   h.registryAuth(),
+		DeviceRequests: h.DeviceRequests,
+		HealthCheck:    h.HealthCheck,
 	}
+}
 
-	if err := h.stack.Add(func(ctx context.Context) error {
-		return cli.Remove(ctx, resp)
-	}); err != nil {
-		return fmt.Errorf("adding container teardown to stack: %w", err)
+// DefaultHealthCheck reports the harness healthy once the docker daemon is
+// reachable through the mounted socket.
+func DefaultHealthCheck() *container.HealthConfig {
+	return &container.HealthConfig{
+		Test:          []string{"CMD", "docker", "info"},
+		Interval:      1 * time.Second,
+		Timeout:       5 * time.Second,
+		Retries:       5,
+		StartInterval: 1 * time.Second,
 	}
+}
 
-	h.runner = func(ctx context.Context, cmd harness.Command) error {
-		return resp.Run(ctx, cmd)
+// command applies the harness defaults to cmd.
+func (h *docker) command(cmd harness.Command) harness.Command {
+	if cmd.User == "" {
+		cmd.User = h.User
 	}
-
-	return nil
+	return cmd
 }
 
 // Run implements harness.Harness.
@@ -131,6 +206,79 @@ func (h *docker) Run(ctx context.Context, cmd harness.Command) error {
 	return h.runner(ctx, cmd)
 }
 
+// registryAuth returns the configured registry credentials in a form that can
+// be used by the daemon to pull the harness image.
+func (h *docker) registryAuth() map[string]registry.AuthConfig {
+	auths := make(map[string]registry.AuthConfig)
+	for k, v := range h.Registries {
+		if v.Auth == nil {
+			continue
+		}
+		auths[k] = registry.AuthConfig{
+			Username: v.Auth.Username,
+			Password: v.Auth.Password,
+			Auth:     v.Auth.Auth,
+		}
+	}
+	return auths
+}
+
+func (h *docker) extraHosts() []string {
+	hosts := []string{}
+	if h.HostDockerInternal {
+		hosts = append(hosts, hostGatewayExtraHost)
+	}
+	return append(hosts, h.ExtraHosts...)
+}
+
+// artifactSource is the subset of the container response used to copy out
+// artifacts.
+type artifactSource interface {
+	GetDir(ctx context.Context, path string) (io.ReadCloser, error)
+}
+
+// CollectArtifacts implements harness.ArtifactCollector.
+func (h *docker) CollectArtifacts(ctx context.Context) error {
+	if len(h.Artifacts) == 0 || h.container == nil {
+		return nil
+	}
+	return h.copyArtifacts(ctx, h.container)
+}
+
+// copyArtifacts copies each of the artifact paths out of the container into
+// the artifacts directory. Every path is attempted even if some fail, since
+// partial artifacts are still useful for debugging.
+func (h *docker) copyArtifacts(ctx context.Context, src artifactSource) error {
+	var errs []error
+	for _, path := range h.Artifacts {
+		if err := copyArtifact(ctx, src, path, h.ArtifactsDir); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		log.Info(ctx, "copied harness artifact", "path", path, "dir", h.ArtifactsDir)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to copy artifacts: %v", errs)
+	}
+
+	return nil
+}
+
+func copyArtifact(ctx context.Context, src artifactSource, path, dst string) error {
+	rc, err := src.GetDir(ctx, path)
+	if err != nil {
+		return fmt.Errorf("getting %s: %w", path, err)
+	}
+	defer rc.Close()
+
+	if err := client.ExtractTar(rc, dst); err != nil {
+		return fmt.Errorf("extracting %s: %w", path, err)
+	}
+
+	return nil
+}
+
 func (h *docker) DebugLogCommand() string {
 	// TODO implement something here
 	return ""
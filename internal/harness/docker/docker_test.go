@@ -0,0 +1,266 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	client "github.com/chainguard-dev/terraform-provider-imagetest/internal/docker"
+	"github.com/chainguard-dev/terraform-provider-imagetest/internal/harness"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	dclient "github.com/docker/docker/client"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostDockerInternal(t *testing.T) {
+	h, err := New()
+	require.NoError(t, err)
+
+	d, ok := h.(*docker)
+	require.True(t, ok)
+
+	if runtime.GOOS == "linux" {
+		require.Equal(t, []string{"host.docker.internal:host-gateway"}, d.extraHosts())
+	} else {
+		require.Empty(t, d.extraHosts())
+	}
+
+	h, err = New(WithHostDockerInternal(true))
+	require.NoError(t, err)
+	require.Contains(t, h.(*docker).extraHosts(), "host.docker.internal:host-gateway")
+
+	h, err = New(WithHostDockerInternal(false))
+	require.NoError(t, err)
+	require.Empty(t, h.(*docker).extraHosts())
+}
+
+func TestWithGPUs(t *testing.T) {
+	h, err := New()
+	require.NoError(t, err)
+	require.Empty(t, h.(*docker).request(nil).DeviceRequests)
+
+	h, err = New(WithGPUs("all"))
+	require.NoError(t, err)
+	require.Equal(t, []container.DeviceRequest{
+		{Driver: "nvidia", Count: -1, Capabilities: [][]string{{"gpu"}}},
+	}, h.(*docker).request(nil).DeviceRequests)
+
+	h, err = New(WithGPUs("2"))
+	require.NoError(t, err)
+	require.Equal(t, []container.DeviceRequest{
+		{Driver: "nvidia", Count: 2, Capabilities: [][]string{{"gpu"}}},
+	}, h.(*docker).request(nil).DeviceRequests)
+
+	for _, gpus := range []string{"some", "0", "-1"} {
+		_, err = New(WithGPUs(gpus))
+		require.Error(t, err, gpus)
+	}
+
+	// The device requests reach the daemon when the harness container starts
+	h, err = New(WithGPUs("all"))
+	require.NoError(t, err)
+	require.Equal(t, []container.DeviceRequest{
+		{Driver: "nvidia", Count: -1, Capabilities: [][]string{{"gpu"}}},
+	}, startRequest(t, h.(*docker)).HostConfig.DeviceRequests)
+}
+
+// startRequest starts the harness container against a fake docker daemon, and
+// returns the create request the daemon received.
+func startRequest(t *testing.T, h *docker) container.CreateRequest {
+	t.Helper()
+
+	var create container.CreateRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Strip the api version prefix (/v1.xx)
+		path := r.URL.Path
+		if parts := strings.SplitN(path, "/", 3); len(parts) == 3 && strings.HasPrefix(parts[1], "v1.") {
+			path = "/" + parts[2]
+		}
+
+		switch {
+		case r.Method == http.MethodGet && strings.HasPrefix(path, "/images/"):
+			_, _ = w.Write([]byte("{}"))
+		case r.Method == http.MethodPost && path == "/containers/create":
+			if err := json.NewDecoder(r.Body).Decode(&create); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(container.CreateResponse{ID: "fake"})
+		case r.Method == http.MethodPut && path == "/containers/fake/archive":
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodPost && path == "/containers/fake/start":
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodGet && path == "/containers/fake/json":
+			_ = json.NewEncoder(w).Encode(types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{
+					ID:   "fake",
+					Name: "/fake",
+					State: &types.ContainerState{
+						Running: true,
+						Health:  &types.Health{Status: "healthy"},
+					},
+				},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	dcli, err := dclient.NewClientWithOpts(
+		dclient.WithHost("tcp://"+srv.Listener.Addr().String()),
+		dclient.WithVersion("1.45"),
+	)
+	require.NoError(t, err)
+
+	cli, err := client.New(client.WithClient(dcli))
+	require.NoError(t, err)
+
+	_, err = cli.Start(context.Background(), h.request(nil))
+	require.NoError(t, err)
+
+	return create
+}
+
+func TestWithUser(t *testing.T) {
+	h, err := New()
+	require.NoError(t, err)
+	require.Empty(t, h.(*docker).command(harness.Command{}).User)
+
+	h, err = New(WithUser("65532"))
+	require.NoError(t, err)
+	require.Equal(t, "65532", h.(*docker).command(harness.Command{}).User)
+
+	// Commands can still override the harness user
+	require.Equal(t, "0", h.(*docker).command(harness.Command{User: "0"}).User)
+
+	// The harness container itself still runs as root
+	require.Equal(t, "0:0", h.(*docker).request(nil).User)
+}
+
+func TestWithExtraHosts(t *testing.T) {
+	h, err := New(
+		WithHostDockerInternal(false),
+		WithExtraHosts("registry.local:10.0.0.2", "api.local:host-gateway", "v6.local:::1"),
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"registry.local:10.0.0.2",
+		"api.local:host-gateway",
+		"v6.local:::1",
+	}, h.(*docker).request(nil).ExtraHosts)
+
+	// Extra hosts are added alongside host.docker.internal
+	h, err = New(WithHostDockerInternal(true), WithExtraHosts("registry.local:10.0.0.2"))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"host.docker.internal:host-gateway",
+		"registry.local:10.0.0.2",
+	}, h.(*docker).request(nil).ExtraHosts)
+
+	for _, host := range []string{"registry.local", ":10.0.0.2", "registry.local:nope"} {
+		_, err := New(WithExtraHosts(host))
+		require.Error(t, err, host)
+	}
+}
+
+func TestWithHealthCheck(t *testing.T) {
+	h, err := New()
+	require.NoError(t, err)
+
+	hc := h.(*docker).request(nil).HealthCheck
+	require.NotNil(t, hc)
+	require.Equal(t, []string{"CMD", "docker", "info"}, hc.Test)
+
+	custom := &container.HealthConfig{
+		Test:     []string{"CMD-SHELL", "docker version"},
+		Interval: 5 * time.Second,
+	}
+	h, err = New(WithHealthCheck(custom))
+	require.NoError(t, err)
+	require.Equal(t, custom, h.(*docker).request(nil).HealthCheck)
+
+	h, err = New(WithHealthCheck(nil))
+	require.NoError(t, err)
+	require.Nil(t, h.(*docker).request(nil).HealthCheck)
+}
+
+// fakeArtifacts serves tar archives of files keyed by container path, like
+// the daemon's copy API.
+type fakeArtifacts map[string]map[string]string
+
+func (f fakeArtifacts) GetDir(_ context.Context, path string) (io.ReadCloser, error) {
+	files, ok := f[path]
+	if !ok {
+		return nil, fmt.Errorf("could not find the file %s in container", path)
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(&buf), nil
+}
+
+func TestWithArtifacts(t *testing.T) {
+	ctx := context.Background()
+	dst := t.TempDir()
+
+	h, err := New(WithArtifacts(dst, "/out", "/var/log/app.log", "/missing"))
+	require.NoError(t, err)
+
+	src := fakeArtifacts{
+		"/out":             {"out/results.xml": "<testsuite/>"},
+		"/var/log/app.log": {"app.log": "started\n"},
+	}
+
+	// Nothing is collected before the container is started
+	d := h.(*docker)
+	require.NoError(t, d.CollectArtifacts(ctx))
+
+	// Missing paths are reported, but don't stop the others being copied
+	d.container = src
+	err = d.CollectArtifacts(ctx)
+	require.ErrorContains(t, err, "/missing")
+
+	data, err := os.ReadFile(filepath.Join(dst, "out", "results.xml"))
+	require.NoError(t, err)
+	require.Equal(t, "<testsuite/>", string(data))
+
+	data, err = os.ReadFile(filepath.Join(dst, "app.log"))
+	require.NoError(t, err)
+	require.Equal(t, "started\n", string(data))
+
+	_, err = New(WithArtifacts(dst, "out"))
+	require.Error(t, err)
+
+	_, err = New(WithArtifacts("", "/out"))
+	require.Error(t, err)
+}
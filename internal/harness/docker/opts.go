@@ -2,8 +2,12 @@ package docker
 
 import (
 	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
 
 	client "github.com/chainguard-dev/terraform-provider-imagetest/internal/docker"
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
@@ -82,6 +86,18 @@ func WithAuthFromStatic(registry, username, password, auth string) Option {
 	}
 }
 
+// WithKeychain sets the keychain used to resolve registry credentials, both
+// for WithAuthFromKeychain and for pulling the harness image. It must come
+// before any WithAuthFromKeychain options to take effect for them.
+func WithKeychain(kc authn.Keychain) Option {
+	return func(opt *docker) error {
+		if kc != nil {
+			opt.keychain = kc
+		}
+		return nil
+	}
+}
+
 func WithAuthFromKeychain(registry string) Option {
 	return func(opt *docker) error {
 		if opt.Registries == nil {
@@ -96,7 +112,7 @@ func WithAuthFromKeychain(registry string) Option {
 			return fmt.Errorf("invalid registry name: %w", err)
 		}
 
-		a, err := authn.DefaultKeychain.Resolve(r)
+		a, err := opt.keychain.Resolve(r)
 		if err != nil {
 			return fmt.Errorf("resolving keychain for registry %s: %w", r.String(), err)
 		}
@@ -142,3 +158,119 @@ func WithVolumes(volumes ...VolumeConfig) Option {
 		return nil
 	}
 }
+
+// WithHostDockerInternal toggles adding a host.docker.internal entry that
+// resolves to the host's gateway. It is enabled by default on linux, and is
+// effectively a no-op on macOS and Windows where Docker Desktop already
+// provides host.docker.internal.
+func WithHostDockerInternal(enabled bool) Option {
+	return func(opt *docker) error {
+		opt.HostDockerInternal = enabled
+		return nil
+	}
+}
+
+// WithExtraHosts adds host:ip entries to the harness container's /etc/hosts.
+// The ip may also be "host-gateway" to resolve to the host.
+func WithExtraHosts(hosts ...string) Option {
+	return func(opt *docker) error {
+		for _, h := range hosts {
+			host, ip, ok := strings.Cut(h, ":")
+			if !ok || host == "" {
+				return fmt.Errorf("invalid extra host %q: must be host:ip", h)
+			}
+			if ip != "host-gateway" && net.ParseIP(ip) == nil {
+				return fmt.Errorf("invalid extra host %q: %q is not an IP", h, ip)
+			}
+			opt.ExtraHosts = append(opt.ExtraHosts, h)
+		}
+		return nil
+	}
+}
+
+// WithRestartPolicy sets the restart policy of the harness container. By
+// default the container is never restarted.
+func WithRestartPolicy(policy container.RestartPolicy) Option {
+	return func(opt *docker) error {
+		opt.RestartPolicy = policy
+		return nil
+	}
+}
+
+// WithAutoRemove toggles removing the harness container on teardown. When
+// disabled the container is stopped but left in place for debugging. Enabled
+// by default.
+func WithAutoRemove(enabled bool) Option {
+	return func(opt *docker) error {
+		opt.AutoRemove = enabled
+		return nil
+	}
+}
+
+// WithGPUs exposes the host's nvidia GPUs to the harness container. gpus is
+// either "all" or the number of GPUs to expose.
+func WithGPUs(gpus string) Option {
+	return func(opt *docker) error {
+		if gpus == "" {
+			return nil
+		}
+
+		req, err := client.GPURequest(gpus)
+		if err != nil {
+			return err
+		}
+
+		opt.DeviceRequests = append(opt.DeviceRequests, req)
+		return nil
+	}
+}
+
+// WithUser sets the user (uid[:gid]) steps are run as. The harness container
+// itself still runs as root so it can manage the docker socket.
+func WithUser(user string) Option {
+	return func(opt *docker) error {
+		opt.User = user
+		return nil
+	}
+}
+
+// WithHealthCheck overrides the harness container's health check. By default
+// the harness waits for `docker info` to succeed, which needs the docker cli in
+// the harness image. A nil health check disables it, and the harness is ready
+// as soon as the container is running.
+func WithHealthCheck(hc *container.HealthConfig) Option {
+	return func(opt *docker) error {
+		opt.HealthCheck = hc
+		return nil
+	}
+}
+
+// WithArtifacts copies the given absolute container paths into dir on the host
+// once all features have run, including when teardown is skipped. Directories
+// are copied recursively, rooted at their base name.
+func WithArtifacts(dir string, paths ...string) Option {
+	return func(opt *docker) error {
+		if len(paths) == 0 {
+			return nil
+		}
+
+		if dir == "" {
+			return fmt.Errorf("an artifacts directory is required")
+		}
+
+		for _, p := range paths {
+			if !filepath.IsAbs(p) {
+				return fmt.Errorf("artifact path %s is not absolute", p)
+			}
+		}
+
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return fmt.Errorf("resolving artifacts directory: %w", err)
+		}
+
+		opt.ArtifactsDir = abs
+		opt.Artifacts = append(opt.Artifacts, paths...)
+		return nil
+	}
+}
@@ -14,12 +14,22 @@ type Harness interface {
 	Run(context.Context, Command) error
 }
 
+// ArtifactCollector is implemented by harnesses that can copy artifacts out
+// once all of their features have run. It is called whether or not the
+// harness is torn down afterwards.
+type ArtifactCollector interface {
+	CollectArtifacts(context.Context) error
+}
+
 type Command struct {
 	Args       string
 	WorkingDir string
-	Env        map[string]string
-	Stdout     io.Writer
-	Stderr     io.Writer
+	// User is the user (uid[:gid]) to run the command as. Harnesses fall back
+	// to their default user when unset.
+	User   string
+	Env    map[string]string
+	Stdout io.Writer
+	Stderr io.Writer
 }
 
 func DefaultEntrypoint() []string {
@@ -7,7 +7,11 @@ import (
 	"fmt"
 	"html/template"
 	"io"
+	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/chainguard-dev/terraform-provider-imagetest/internal/docker"
@@ -17,6 +21,7 @@ import (
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/go-connections/nat"
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/uuid"
 	corev1 "k8s.io/api/core/v1"
@@ -36,8 +41,15 @@ type k3s struct {
 
 	Hooks Hooks
 
-	stack  *harness.Stack
-	runner func(context.Context, harness.Command) error
+	// kubeconfigPath is a host path the cluster's kubeconfig is written to,
+	// for use outside of the harness.
+	kubeconfigPath string
+	// kubeconfigCleanup removes the kubeconfig's directory on teardown.
+	kubeconfigCleanup bool
+
+	keychain authn.Keychain
+	stack    *harness.Stack
+	runner   func(context.Context, harness.Command) error
 
 	kcfg *rest.Config
 	kcli kubernetes.Interface
@@ -84,7 +96,8 @@ func New(opts ...Option) (*k3s, error) {
 			},
 			Init: true,
 		},
-		stack: harness.NewStack(),
+		keychain: authn.DefaultKeychain,
+		stack:    harness.NewStack(),
 	}
 
 	for _, opt := range opts {
@@ -100,7 +113,7 @@ func New(opts ...Option) (*k3s, error) {
 func (h *k3s) Create(ctx context.Context) error {
 	// Create the k3s cluster itself
 
-	cli, err := docker.New()
+	cli, err := docker.New(docker.WithKeychain(h.keychain))
 	if err != nil {
 		return err
 	}
@@ -175,16 +188,24 @@ rules:
 		contents = append(contents, kcfg)
 	}
 
+	if len(h.Service.CoreDNSHosts) > 0 {
+		cdns, err := h.coreDNSCustom()
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, cdns)
+	}
+
 	resp, err := cli.Start(ctx, &docker.Request{
 		Name:       name,
 		Ref:        h.Service.Ref,
-		Cmd:        []string{"server"},
+		Cmd:        h.serverCmd(),
 		Privileged: true,
 		Networks: append(h.Service.Networks, docker.NetworkAttachment{
 			Name: nw.Name,
 			ID:   nw.ID,
 		}),
-		Mounts: []mount.Mount{
+		Mounts: append([]mount.Mount{
 			{
 				Type:   mount.TypeTmpfs,
 				Target: "/run",
@@ -193,7 +214,7 @@ rules:
 				Type:   mount.TypeTmpfs,
 				Target: "/tmp",
 			},
-		},
+		}, h.preloadMounts()...),
 		HealthCheck: &container.HealthConfig{
 			Test:          []string{"CMD", "/bin/sh", "-c", "kubectl get --raw='/healthz'"},
 			Interval:      1 * time.Second,
@@ -248,6 +269,10 @@ rules:
 		return nil, fmt.Errorf("getting kubeconfig: %w", err)
 	}
 
+	if err := h.exportKubeconfig(kcfg); err != nil {
+		return nil, err
+	}
+
 	h.kcfg, err = clientcmd.RESTConfigFromKubeConfig(kcfg)
 	if err != nil {
 		return nil, fmt.Errorf("creating kubernetes config: %w", err)
@@ -263,6 +288,14 @@ rules:
 		return nil, fmt.Errorf("adding registry secret: %w", err)
 	}
 
+	for _, cmd := range h.preloadCommands() {
+		if err := resp.Run(ctx, harness.Command{
+			Args: cmd,
+		}); err != nil {
+			return nil, fmt.Errorf("preloading images: %w", err)
+		}
+	}
+
 	// Run the post start hooks after we're all done with the cluster setup
 	for _, hook := range h.Hooks.PostStart {
 		if err := resp.Run(ctx, harness.Command{
@@ -400,6 +433,94 @@ configs:
 	return docker.NewContentFromString(cfg, "/etc/rancher/k3s/registries.yaml"), nil
 }
 
+// serverCmd is the command the k3s service container is started with.
+func (h *k3s) serverCmd() []string {
+	return append([]string{"server"}, h.Service.ServerArgs...)
+}
+
+// preloadImagesDir is where image tarballs to preload are mounted in the k3s
+// service container.
+const preloadImagesDir = "/var/lib/imagetest/images"
+
+// preloadMounts mounts the image tarballs to preload into the k3s service
+// container.
+func (h *k3s) preloadMounts() []mount.Mount {
+	mounts := make([]mount.Mount, 0, len(h.Service.PreloadImages))
+	for i, path := range h.Service.PreloadImages {
+		mounts = append(mounts, mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   path,
+			Target:   preloadImageTarget(i, path),
+			ReadOnly: true,
+		})
+	}
+	return mounts
+}
+
+// preloadCommands returns the commands that import the preloaded image
+// tarballs into the k8s.io containerd namespace used by the kubelet.
+func (h *k3s) preloadCommands() []string {
+	cmds := make([]string, 0, len(h.Service.PreloadImages))
+	for i, path := range h.Service.PreloadImages {
+		cmds = append(cmds, fmt.Sprintf("k3s ctr --namespace k8s.io images import %s", preloadImageTarget(i, path)))
+	}
+	return cmds
+}
+
+// preloadImageTarget is the mount path of a preloaded tarball. The index
+// keeps tarballs with the same file name from colliding.
+func preloadImageTarget(i int, path string) string {
+	return fmt.Sprintf("%s/%d-%s", preloadImagesDir, i, filepath.Base(path))
+}
+
+// coreDNSCustom returns a k3s auto-deploy manifest for the coredns-custom
+// ConfigMap, which k3s' CoreDNS imports. Deploying it as a manifest means it
+// exists before CoreDNS starts, so CoreDNS doesn't need to be restarted to
+// pick it up.
+func (h *k3s) coreDNSCustom() (*docker.Content, error) {
+	data, err := json.Marshal(coreDNSCustomConfigMap(h.Service.CoreDNSHosts))
+	if err != nil {
+		return nil, fmt.Errorf("marshaling coredns-custom configmap: %w", err)
+	}
+
+	// json is valid yaml, so the manifest can be written as is
+	return docker.NewContentFromString(string(data), "/var/lib/rancher/k3s/server/manifests/imagetest-coredns-custom.yaml"), nil
+}
+
+// coreDNSCustomConfigMap builds the coredns-custom ConfigMap with a server
+// block that serves the given hosts.
+func coreDNSCustomConfigMap(hosts map[string]string) *corev1.ConfigMap {
+	names := make([]string, 0, len(hosts))
+	for host := range hosts {
+		names = append(names, host)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s:53 {\n", strings.Join(names, " "))
+	sb.WriteString("    errors\n")
+	sb.WriteString("    hosts {\n")
+	for _, host := range names {
+		fmt.Fprintf(&sb, "        %s %s\n", hosts[host], host)
+	}
+	sb.WriteString("    }\n")
+	sb.WriteString("}\n")
+
+	return &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "coredns-custom",
+			Namespace: "kube-system",
+		},
+		Data: map[string]string{
+			"imagetest.server": sb.String(),
+		},
+	}
+}
+
 func (h *k3s) kubeconfig(ctx context.Context, resp *docker.Response, config func(cfg *api.Config) error) ([]byte, error) {
 	// Setup host's kube client
 	kr, err := resp.GetFile(ctx, "/etc/rancher/k3s/k3s.yaml")
@@ -428,6 +549,40 @@ func (h *k3s) kubeconfig(ctx context.Context, resp *docker.Response, config func
 	return clientcmd.Write(*kcfg)
 }
 
+// exportKubeconfig writes the kubeconfig to the configured host path, if any.
+// When kubeconfigCleanup is set, its directory is removed with the rest of the
+// harness, and kept along with it when teardown is skipped.
+func (h *k3s) exportKubeconfig(kcfg []byte) error {
+	if h.kubeconfigPath == "" {
+		return nil
+	}
+
+	if h.kubeconfigCleanup {
+		dir := filepath.Dir(h.kubeconfigPath)
+		if err := h.stack.Add(func(ctx context.Context) error {
+			return os.RemoveAll(dir)
+		}); err != nil {
+			return fmt.Errorf("adding kubeconfig teardown to stack: %w", err)
+		}
+	}
+
+	return writeKubeconfig(h.kubeconfigPath, kcfg)
+}
+
+// writeKubeconfig writes the kubeconfig to path, creating any parent
+// directories.
+func writeKubeconfig(path string, kcfg []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating kubeconfig directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, kcfg, 0o600); err != nil {
+		return fmt.Errorf("writing kubeconfig: %w", err)
+	}
+
+	return nil
+}
+
 func (h *k3s) registrySecret(ctx context.Context) error {
 	dockerconfig := configfile.ConfigFile{
 		AuthConfigs: make(map[string]dtypes.AuthConfig),
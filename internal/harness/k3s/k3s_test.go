@@ -2,6 +2,8 @@ package k3s
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -72,3 +74,207 @@ func TestK3sSandboxInit(t *testing.T) {
 	err = h.Destroy(ctx)
 	require.NoError(t, err)
 }
+
+func TestWithWaitFor(t *testing.T) {
+	tests := []struct {
+		name      string
+		kind      string
+		resource  string
+		namespace string
+		timeout   time.Duration
+		want      string
+	}{
+		{
+			name:      "deployment",
+			kind:      "Deployment",
+			resource:  "coredns",
+			namespace: "kube-system",
+			timeout:   2 * time.Minute,
+			want:      "kubectl wait --for=condition=Available --namespace kube-system --timeout 2m0s deployment/coredns",
+		},
+		{
+			name:     "daemonset defaults",
+			kind:     "daemonset",
+			resource: "foo",
+			want:     "kubectl rollout status --namespace default --timeout 5m0s daemonset/foo",
+		},
+		{
+			name:      "job",
+			kind:      "job",
+			resource:  "migrate",
+			namespace: "app",
+			timeout:   30 * time.Second,
+			want:      "kubectl wait --for=condition=Complete --namespace app --timeout 30s job/migrate",
+		},
+		{
+			name:      "pod",
+			kind:      "pod",
+			resource:  "foo",
+			namespace: "app",
+			timeout:   time.Minute,
+			want:      "kubectl wait --for=condition=Ready --namespace app --timeout 1m0s pod/foo",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := New(
+				WithHooks(Hooks{PostStart: []string{"echo hello"}}),
+				WithWaitFor(tt.kind, tt.resource, tt.namespace, tt.timeout),
+			)
+			require.NoError(t, err)
+			require.Equal(t, []string{"echo hello", tt.want}, h.Hooks.PostStart)
+		})
+	}
+
+	// Hooks configured after waiting aren't lost
+	h, err := New(
+		WithWaitFor("deployment", "coredns", "kube-system", time.Minute),
+		WithHooks(Hooks{PostStart: []string{"echo hello"}}),
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"kubectl wait --for=condition=Available --namespace kube-system --timeout 1m0s deployment/coredns",
+		"echo hello",
+	}, h.Hooks.PostStart)
+
+	_, err = New(WithWaitFor("deployment", "", "", 0))
+	require.Error(t, err)
+
+	for _, opt := range []Option{
+		WithWaitFor("deployment", "foo; rm -rf /", "", 0),
+		WithWaitFor("deployment $(id)", "foo", "", 0),
+		WithWaitFor("deployment", "foo", "default && id", 0),
+	} {
+		_, err = New(opt)
+		require.Error(t, err)
+	}
+}
+
+func TestWithKubeconfigPath(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nested", "kubeconfig.yaml")
+
+	h, err := New(WithKubeconfigPath(path))
+	require.NoError(t, err)
+	require.Equal(t, path, h.kubeconfigPath)
+
+	require.NoError(t, writeKubeconfig(h.kubeconfigPath, []byte("apiVersion: v1\nkind: Config\n")))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "apiVersion: v1\nkind: Config\n", string(data))
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	// Relative paths are resolved against the working directory
+	h, err = New(WithKubeconfigPath("kubeconfig.yaml"))
+	require.NoError(t, err)
+	require.True(t, filepath.IsAbs(h.kubeconfigPath))
+}
+
+func TestWithKubeconfigCleanup(t *testing.T) {
+	ctx := context.Background()
+	kcfg := []byte("apiVersion: v1\nkind: Config\n")
+
+	// Without cleanup, the kubeconfig outlives the harness
+	kept := filepath.Join(t.TempDir(), "kept", "kubeconfig")
+	h, err := New(WithKubeconfigPath(kept))
+	require.NoError(t, err)
+	require.NoError(t, h.exportKubeconfig(kcfg))
+	require.NoError(t, h.Destroy(ctx))
+	require.FileExists(t, kept)
+
+	removed := filepath.Join(t.TempDir(), "removed", "kubeconfig")
+	h, err = New(WithKubeconfigPath(removed), WithKubeconfigCleanup())
+	require.NoError(t, err)
+	require.NoError(t, h.exportKubeconfig(kcfg))
+	require.FileExists(t, removed)
+	require.NoError(t, h.Destroy(ctx))
+	require.NoDirExists(t, filepath.Dir(removed))
+}
+
+func TestWithCoreDNSHosts(t *testing.T) {
+	h, err := New(WithCoreDNSHosts(map[string]string{
+		"registry.local": "10.0.0.2",
+		"api.local":      "10.0.0.3",
+	}))
+	require.NoError(t, err)
+
+	cm := coreDNSCustomConfigMap(h.Service.CoreDNSHosts)
+	require.Equal(t, "coredns-custom", cm.Name)
+	require.Equal(t, "kube-system", cm.Namespace)
+	require.Equal(t, `api.local registry.local:53 {
+    errors
+    hosts {
+        10.0.0.3 api.local
+        10.0.0.2 registry.local
+    }
+}
+`, cm.Data["imagetest.server"])
+
+	_, err = New(WithCoreDNSHosts(map[string]string{"registry.local": "not-an-ip"}))
+	require.Error(t, err)
+
+	_, err = New(WithCoreDNSHosts(map[string]string{"": "10.0.0.2"}))
+	require.Error(t, err)
+}
+
+func TestWithPreloadImages(t *testing.T) {
+	dir := t.TempDir()
+	for _, p := range []string{"app.tar", filepath.Join("other", "app.tar")} {
+		path := filepath.Join(dir, p)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte("tar"), 0o644))
+	}
+
+	h, err := New()
+	require.NoError(t, err)
+	require.Empty(t, h.preloadMounts())
+	require.Empty(t, h.preloadCommands())
+
+	h, err = New(WithPreloadImages(
+		filepath.Join(dir, "app.tar"),
+		filepath.Join(dir, "other", "app.tar"),
+	))
+	require.NoError(t, err)
+
+	mounts := h.preloadMounts()
+	require.Len(t, mounts, 2)
+	require.Equal(t, filepath.Join(dir, "app.tar"), mounts[0].Source)
+	require.Equal(t, "/var/lib/imagetest/images/0-app.tar", mounts[0].Target)
+	require.True(t, mounts[0].ReadOnly)
+	require.Equal(t, "/var/lib/imagetest/images/1-app.tar", mounts[1].Target)
+
+	require.Equal(t, []string{
+		"k3s ctr --namespace k8s.io images import /var/lib/imagetest/images/0-app.tar",
+		"k3s ctr --namespace k8s.io images import /var/lib/imagetest/images/1-app.tar",
+	}, h.preloadCommands())
+
+	_, err = New(WithPreloadImages(filepath.Join(dir, "missing.tar")))
+	require.Error(t, err)
+}
+
+func TestWithServerArgs(t *testing.T) {
+	h, err := New()
+	require.NoError(t, err)
+	require.Equal(t, []string{"server"}, h.serverCmd())
+
+	h, err = New(WithServerArgs(
+		"--kube-apiserver-arg=feature-gates=InPlacePodVerticalScaling=true",
+		"--kubelet-arg=max-pods=250",
+	))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"server",
+		"--kube-apiserver-arg=feature-gates=InPlacePodVerticalScaling=true",
+		"--kubelet-arg=max-pods=250",
+	}, h.serverCmd())
+
+	for _, arg := range []string{"--disable-agent", "kubelet-arg=max-pods=250", "--=value", ""} {
+		_, err := New(WithServerArgs(arg))
+		require.Error(t, err, arg)
+	}
+}
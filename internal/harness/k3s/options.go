@@ -2,6 +2,11 @@ package k3s
 
 import (
 	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/chainguard-dev/terraform-provider-imagetest/internal/docker"
 	"github.com/docker/docker/api/types/mount"
@@ -9,6 +14,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/name"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/validation"
 	kubeletconfigv1beta1 "k8s.io/kubelet/config/v1beta1"
 )
 
@@ -28,6 +34,9 @@ type serviceConfig struct {
 	Mirrors         map[string]*MirrorConfig
 	Resources       docker.ResourcesRequest
 	Networks        []docker.NetworkAttachment // A list of existing networks names (or network aliases) to attach the harness containers to.
+	CoreDNSHosts    map[string]string          // Static hostname to IP entries served by the cluster's CoreDNS.
+	PreloadImages   []string                   // Host paths of image tarballs imported into containerd on startup.
+	ServerArgs      []string                   // Extra flags passed to k3s server, such as --kube-apiserver-arg=feature-gates=...
 }
 
 type RegistryConfig struct {
@@ -129,6 +138,18 @@ func WithAuthFromStatic(registry, username, password, auth string) Option {
 	}
 }
 
+// WithKeychain sets the keychain used to resolve registry credentials, both
+// for WithAuthFromKeychain and for pulling the harness images. It must come
+// before any WithAuthFromKeychain options to take effect for them.
+func WithKeychain(kc authn.Keychain) Option {
+	return func(h *k3s) error {
+		if kc != nil {
+			h.keychain = kc
+		}
+		return nil
+	}
+}
+
 func WithAuthFromKeychain(registry string) Option {
 	return func(h *k3s) error {
 		if h.Service.Registries == nil {
@@ -143,7 +164,7 @@ func WithAuthFromKeychain(registry string) Option {
 			return fmt.Errorf("invalid registry name: %w", err)
 		}
 
-		a, err := authn.DefaultKeychain.Resolve(r)
+		a, err := h.keychain.Resolve(r)
 		if err != nil {
 			return fmt.Errorf("resolving keychain for registry %s: %w", r.String(), err)
 		}
@@ -189,9 +210,147 @@ func WithSnapshotter(snapshotter Snapshotter) Option {
 	}
 }
 
+// WithHooks appends the given hooks to any already configured, such as those
+// added by WithWaitFor.
 func WithHooks(hooks Hooks) Option {
 	return func(opt *k3s) error {
-		opt.Hooks = hooks
+		opt.Hooks.PostStart = append(opt.Hooks.PostStart, hooks.PostStart...)
+		return nil
+	}
+}
+
+// WithWaitFor appends a post start hook that blocks until the given workload
+// is ready. Deployments wait for the Available condition, rollout based
+// workloads (statefulsets, daemonsets) wait on the rollout status, jobs wait
+// for completion, and everything else waits for the Ready condition.
+func WithWaitFor(kind, name, namespace string, timeout time.Duration) Option {
+	return func(opt *k3s) error {
+		if kind == "" || name == "" {
+			return fmt.Errorf("kind and name are required to wait for a workload")
+		}
+
+		// The values end up in a shell command, so only allow valid names
+		for field, value := range map[string]string{"kind": strings.ToLower(kind), "name": name} {
+			if errs := validation.IsDNS1123Subdomain(value); len(errs) > 0 {
+				return fmt.Errorf("invalid %s %q to wait for: %s", field, value, strings.Join(errs, ", "))
+			}
+		}
+		if namespace != "" {
+			if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+				return fmt.Errorf("invalid namespace %q to wait for: %s", namespace, strings.Join(errs, ", "))
+			}
+		}
+		opt.Hooks.PostStart = append(opt.Hooks.PostStart, waitForCmd(kind, name, namespace, timeout))
+		return nil
+	}
+}
+
+func waitForCmd(kind, name, namespace string, timeout time.Duration) string {
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	if timeout == 0 {
+		timeout = 5 * time.Minute
+	}
+
+	kind = strings.ToLower(kind)
+	resource := fmt.Sprintf("%s/%s", kind, name)
+
+	switch kind {
+	case "deployment", "deploy":
+		return fmt.Sprintf("kubectl wait --for=condition=Available --namespace %s --timeout %s %s", namespace, timeout, resource)
+	case "statefulset", "sts", "daemonset", "ds":
+		return fmt.Sprintf("kubectl rollout status --namespace %s --timeout %s %s", namespace, timeout, resource)
+	case "job":
+		return fmt.Sprintf("kubectl wait --for=condition=Complete --namespace %s --timeout %s %s", namespace, timeout, resource)
+	default:
+		return fmt.Sprintf("kubectl wait --for=condition=Ready --namespace %s --timeout %s %s", namespace, timeout, resource)
+	}
+}
+
+// WithKubeconfigPath writes the cluster's kubeconfig, reachable from the
+// host, to the given path once the cluster is running.
+func WithKubeconfigPath(path string) Option {
+	return func(opt *k3s) error {
+		if path == "" {
+			return nil
+		}
+
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("resolving kubeconfig path: %w", err)
+		}
+		opt.kubeconfigPath = abs
+		return nil
+	}
+}
+
+// WithKubeconfigCleanup removes the directory containing the kubeconfig
+// written by WithKubeconfigPath when the harness is torn down. It is meant for
+// kubeconfigs written to a directory owned by the harness.
+func WithKubeconfigCleanup() Option {
+	return func(opt *k3s) error {
+		opt.kubeconfigCleanup = true
+		return nil
+	}
+}
+
+// WithServerArgs passes extra flags to the k3s server, such as
+// --kube-apiserver-arg=enable-admission-plugins=... or
+// --kubelet-arg=feature-gates=.... Args must be in the --key=value form.
+func WithServerArgs(args ...string) Option {
+	return func(opt *k3s) error {
+		for _, arg := range args {
+			key, _, ok := strings.Cut(arg, "=")
+			if !ok || !strings.HasPrefix(key, "--") || len(key) == 2 {
+				return fmt.Errorf("invalid server arg %q: must be --key=value", arg)
+			}
+			opt.Service.ServerArgs = append(opt.Service.ServerArgs, arg)
+		}
+		return nil
+	}
+}
+
+// WithPreloadImages imports the given image tarballs (as produced by docker
+// save or crane pull) into the cluster's containerd on startup, so pods can
+// use images that aren't in any registry.
+func WithPreloadImages(paths ...string) Option {
+	return func(opt *k3s) error {
+		for _, path := range paths {
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return fmt.Errorf("resolving preload image path: %w", err)
+			}
+
+			if _, err := os.Stat(abs); err != nil {
+				return fmt.Errorf("preload image %s: %w", path, err)
+			}
+
+			opt.Service.PreloadImages = append(opt.Service.PreloadImages, abs)
+		}
+		return nil
+	}
+}
+
+// WithCoreDNSHosts adds static hostname to IP entries that are resolvable
+// from within the cluster. The entries are served from the coredns-custom
+// ConfigMap, which is deployed alongside CoreDNS when the cluster starts.
+func WithCoreDNSHosts(hosts map[string]string) Option {
+	return func(opt *k3s) error {
+		if opt.Service.CoreDNSHosts == nil {
+			opt.Service.CoreDNSHosts = make(map[string]string)
+		}
+
+		for host, ip := range hosts {
+			if host == "" {
+				return fmt.Errorf("coredns host must not be empty")
+			}
+			if net.ParseIP(ip) == nil {
+				return fmt.Errorf("invalid IP %q for coredns host %s", ip, host)
+			}
+			opt.Service.CoreDNSHosts[host] = ip
+		}
 		return nil
 	}
 }
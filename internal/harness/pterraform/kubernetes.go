@@ -13,6 +13,10 @@ type KubernetesConnection struct {
 	Kubeconfig     string `json:"kubeconfig"`
 	KubeconfigPath string `json:"kubeconfig_path"`
 	SandboxImage   string `json:"sandbox_image"`
+	WorkingDir     string `json:"working_dir"`
+	// Labels and Annotations are added to the sandbox pod.
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
 }
 
 func (k *KubernetesConnection) runner() (sandbox.Sandbox, error) {
@@ -20,9 +24,20 @@ func (k *KubernetesConnection) runner() (sandbox.Sandbox, error) {
 	if err != nil {
 		return nil, err
 	}
-	return k8s.NewFromConfig(cfg,
+
+	opts := []k8s.Option{
 		k8s.WithRawImageRef(k.SandboxImage),
-	)
+		k8s.WithLabels(k.Labels),
+		k8s.WithAnnotations(k.Annotations),
+	}
+
+	// Unless set, steps run in the sandbox image's own working directory,
+	// which is where its test content lives
+	if k.WorkingDir != "" {
+		opts = append(opts, k8s.WithWorkingDir(k.WorkingDir))
+	}
+
+	return k8s.NewFromConfig(cfg, opts...)
 }
 
 func (k *KubernetesConnection) parse() (*rest.Config, error) {
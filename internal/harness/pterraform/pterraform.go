@@ -321,6 +321,12 @@ func (p *pterraform) Create(ctx context.Context) error {
 			return err
 		}
 
+		// Tear down the sandbox, reporting its resource usage, before the
+		// infrastructure it runs on
+		if err := p.stack.Add(sbx.Destroy); err != nil {
+			return fmt.Errorf("adding sandbox teardown to stack: %w", err)
+		}
+
 		if err := wait.ExponentialBackoffWithContext(ctx, conn.backoff, func(ctx context.Context) (bool, error) {
 			r, err := sbx.Start(ctx)
 			if err != nil {
@@ -17,6 +17,9 @@ type Inventory struct {
 type Harness struct {
 	Id       string             `json:"id"`
 	Features map[string]Feature `json:"features"`
+	// SkipTeardown is the reason the harness teardown is skipped, recorded by
+	// the first removed feature that asked for it, or an empty string
+	SkipTeardown string `json:"skip_teardown,omitempty"`
 }
 
 type Feature struct {
@@ -49,9 +52,10 @@ func (i *Inventory) AddHarness(ctx context.Context, id string) error {
 	}
 	defer f.Close()
 
-	fs := make(map[string]Feature)
-
-	return json.NewEncoder(f).Encode(fs)
+	return json.NewEncoder(f).Encode(Harness{
+		Id:       id,
+		Features: make(map[string]Feature),
+	})
 }
 
 // AddFeature adds a feature to an existing harness. It returns an error if the harness does not exist.
@@ -61,8 +65,6 @@ func (i *Inventory) AddFeature(ctx context.Context, harness string, feature Feat
 		return fmt.Errorf("harness [%s] does not exist at [%s]: base %s: %v", harness, hpath, i.base, err)
 	}
 
-	fs := make(map[string]Feature)
-
 	i.mu.Lock()
 	defer i.mu.Unlock()
 
@@ -71,11 +73,15 @@ func (i *Inventory) AddFeature(ctx context.Context, harness string, feature Feat
 		return fmt.Errorf("failed to read harness: %w", err)
 	}
 
-	if err := json.Unmarshal(data, &fs); err != nil {
+	var h Harness
+	if err := json.Unmarshal(data, &h); err != nil {
 		return fmt.Errorf("failed to unmarshal harness: %w", err)
 	}
 
-	fs[feature.Id] = feature
+	if h.Features == nil {
+		h.Features = make(map[string]Feature)
+	}
+	h.Features[feature.Id] = feature
 
 	fw, err := os.OpenFile(hpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
@@ -83,7 +89,7 @@ func (i *Inventory) AddFeature(ctx context.Context, harness string, feature Feat
 	}
 	defer fw.Close()
 
-	if err := json.NewEncoder(fw).Encode(fs); err != nil {
+	if err := json.NewEncoder(fw).Encode(h); err != nil {
 		return fmt.Errorf("failed to encode harness: %w", err)
 	}
 
@@ -91,10 +97,21 @@ func (i *Inventory) AddFeature(ctx context.Context, harness string, feature Feat
 }
 
 func (i *Inventory) GetFeatures(ctx context.Context, id string) (map[string]Feature, error) {
+	h, err := i.GetHarness(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.Features, nil
+}
+
+// GetHarness returns the harness with the given id, including its remaining
+// features and whether its teardown is skipped.
+func (i *Inventory) GetHarness(ctx context.Context, id string) (Harness, error) {
 	hpath := i.harnessPath(id)
 
 	if _, err := os.Stat(hpath); err != nil {
-		return nil, fmt.Errorf("harness [%s] does not exist at [%s]: %v", id, hpath, err)
+		return Harness{}, fmt.Errorf("harness [%s] does not exist at [%s]: %v", id, hpath, err)
 	}
 
 	i.mu.RLock()
@@ -102,17 +119,20 @@ func (i *Inventory) GetFeatures(ctx context.Context, id string) (map[string]Feat
 
 	f, err := os.Open(hpath)
 	if err != nil {
-		return nil, err
+		return Harness{}, err
 	}
 	defer f.Close()
 
-	fs := make(map[string]Feature)
+	var h Harness
+	if err := json.NewDecoder(f).Decode(&h); err != nil {
+		return Harness{}, fmt.Errorf("failed to unmarshal harness: %v", err)
+	}
 
-	if err := json.NewDecoder(f).Decode(&fs); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal harness: %v", err)
+	if h.Features == nil {
+		h.Features = make(map[string]Feature)
 	}
 
-	return fs, nil
+	return h, nil
 }
 
 func (i *Inventory) RemoveHarness(ctx context.Context, id string) error {
@@ -126,12 +146,12 @@ func (i *Inventory) RemoveHarness(ctx context.Context, id string) error {
 		return fmt.Errorf("failed to read harness [%s]: %w", id, err)
 	}
 
-	fs := make(map[string]Feature)
-	if err := json.Unmarshal(data, &fs); err != nil {
+	var h Harness
+	if err := json.Unmarshal(data, &h); err != nil {
 		return fmt.Errorf("failed to unmarshal harness [%s]: %w", id, err)
 	}
 
-	if len(fs) > 0 {
+	if len(h.Features) > 0 {
 		return fmt.Errorf("cannot remove harness [%s]: harness contains features", id)
 	}
 
@@ -143,14 +163,16 @@ func (i *Inventory) RemoveHarness(ctx context.Context, id string) error {
 	return nil
 }
 
-func (i *Inventory) RemoveFeature(ctx context.Context, harness string, id string) error {
+// RemoveFeature removes a feature from an existing harness. A non-empty
+// skipTeardown reason is recorded on the harness, so the harness teardown is
+// skipped when its last feature is removed, regardless of which feature that
+// is. The first recorded reason is kept.
+func (i *Inventory) RemoveFeature(ctx context.Context, harness string, id string, skipTeardown string) error {
 	hpath := i.harnessPath(harness)
 
 	i.mu.Lock()
 	defer i.mu.Unlock()
 
-	fs := make(map[string]Feature)
-
 	data, err := os.ReadFile(hpath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -159,15 +181,20 @@ func (i *Inventory) RemoveFeature(ctx context.Context, harness string, id string
 		return fmt.Errorf("failed to read harness [%s]: %w", harness, err)
 	}
 
-	if err := json.Unmarshal(data, &fs); err != nil {
+	var h Harness
+	if err := json.Unmarshal(data, &h); err != nil {
 		return fmt.Errorf("failed to unmarshal harness [%s]: %w", harness, err)
 	}
 
-	if _, exists := fs[id]; !exists {
+	if _, exists := h.Features[id]; !exists {
 		return fmt.Errorf("feature [%s] does not exist in harness [%s]", id, harness)
 	}
 
-	delete(fs, id)
+	delete(h.Features, id)
+
+	if h.SkipTeardown == "" {
+		h.SkipTeardown = skipTeardown
+	}
 
 	fw, err := os.OpenFile(hpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
@@ -175,7 +202,7 @@ func (i *Inventory) RemoveFeature(ctx context.Context, harness string, id string
 	}
 	defer fw.Close()
 
-	if err := json.NewEncoder(fw).Encode(fs); err != nil {
+	if err := json.NewEncoder(fw).Encode(h); err != nil {
 		return fmt.Errorf("failed to encode harness [%s]: %w", harness, err)
 	}
 
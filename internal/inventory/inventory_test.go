@@ -240,7 +240,7 @@ func TestInventory_RemoveFeature(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			err = inv.RemoveFeature(context.Background(), tc.harness, tc.feature)
+			err = inv.RemoveFeature(context.Background(), tc.harness, tc.feature, "")
 			if (err != nil) != tc.wantErr {
 				t.Errorf("RemoveFeature() error = %v, wantErr %v", err, tc.wantErr)
 			}
@@ -248,6 +248,49 @@ func TestInventory_RemoveFeature(t *testing.T) {
 	}
 }
 
+func TestInventory_RemoveFeatureSkipTeardown(t *testing.T) {
+	ctx := context.Background()
+	inv := tinv(t)
+
+	h1 := "foo"
+	if err := inv.AddHarness(ctx, h1); err != nil {
+		t.Fatalf("Failed to add harness: %v", err)
+	}
+	for _, f := range []string{"a", "b", "c"} {
+		if err := inv.AddFeature(ctx, h1, inventory.Feature{Id: f}); err != nil {
+			t.Fatalf("Failed to add feature: %v", err)
+		}
+	}
+
+	// The first reason recorded is kept, even once later features are removed
+	// without one
+	for _, rm := range []struct {
+		feature string
+		reason  string
+	}{
+		{"a", "first"},
+		{"b", "second"},
+		{"c", ""},
+	} {
+		if err := inv.RemoveFeature(ctx, h1, rm.feature, rm.reason); err != nil {
+			t.Fatalf("Failed to remove feature: %v", err)
+		}
+	}
+
+	got, err := inv.GetHarness(ctx, h1)
+	if err != nil {
+		t.Fatalf("GetHarness() error = %v", err)
+	}
+	want := inventory.Harness{
+		Id:           h1,
+		Features:     map[string]inventory.Feature{},
+		SkipTeardown: "first",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetHarness() mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func TestInventory_Concurrency(t *testing.T) {
 	inv := tinv(t)
 	ctx := context.Background()
@@ -2,6 +2,7 @@ package log
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"runtime"
 	"time"
@@ -25,6 +26,21 @@ func Error(ctx context.Context, msg string, args ...any) {
 	log(ctx, clog.FromContext(ctx), slog.LevelError, msg, args...)
 }
 
+// ParseLevel parses one of debug, info, warn or error into a slog.Level.
+func ParseLevel(s string) (slog.Level, error) {
+	switch s {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("invalid log level %q: must be one of debug, info, warn, error", s)
+}
+
 func With(ctx context.Context, args ...any) context.Context {
 	logger := clog.FromContext(ctx).With(args...)
 	return clog.WithLogger(ctx, logger)
@@ -0,0 +1,45 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLevel(t *testing.T) {
+	for s, want := range map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"info":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+	} {
+		got, err := ParseLevel(s)
+		require.NoError(t, err, s)
+		require.Equal(t, want, got, s)
+	}
+
+	for _, s := range []string{"", "trace", "INFO+2"} {
+		_, err := ParseLevel(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestTFHandlerLevel(t *testing.T) {
+	ctx := context.Background()
+
+	// Without a level, everything is passed on to tflog
+	h := &TFHandler{}
+	require.True(t, h.Enabled(ctx, slog.LevelDebug))
+
+	h = &TFHandler{Level: slog.LevelWarn}
+	require.False(t, h.Enabled(ctx, slog.LevelDebug))
+	require.False(t, h.Enabled(ctx, slog.LevelInfo))
+	require.True(t, h.Enabled(ctx, slog.LevelWarn))
+	require.True(t, h.Enabled(ctx, slog.LevelError))
+
+	// The level is kept by derived handlers
+	require.False(t, h.WithAttrs([]slog.Attr{slog.String("k", "v")}).Enabled(ctx, slog.LevelInfo))
+	require.False(t, h.WithGroup("g").Enabled(ctx, slog.LevelInfo))
+}
@@ -8,6 +8,10 @@ import (
 )
 
 type TFHandler struct {
+	// Level is the minimum level of records that are handled. When unset,
+	// all records are passed on to tflog.
+	Level slog.Leveler
+
 	attrs  []slog.Attr
 	groups []string
 }
@@ -15,7 +19,10 @@ type TFHandler struct {
 const subsystem = "imagetest"
 
 // Enabled implements slog.Handler.
-func (h *TFHandler) Enabled(_ context.Context, _ slog.Level) bool {
+func (h *TFHandler) Enabled(_ context.Context, level slog.Level) bool {
+	if h.Level != nil {
+		return level >= h.Level.Level()
+	}
 	// Rely on the handler to filter this out, tflog doesn't provide a public API
 	// for determining the providers log level :|
 	return true
@@ -60,12 +67,17 @@ func (h *TFHandler) Handle(ctx context.Context, record slog.Record) error {
 
 // WithAttrs implements slog.Handler.
 func (h *TFHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	return &TFHandler{attrs: append(h.attrs, attrs...)}
+	return &TFHandler{
+		Level:  h.Level,
+		attrs:  append(h.attrs, attrs...),
+		groups: h.groups,
+	}
 }
 
 // WithGroup implements slog.Handler.
 func (h *TFHandler) WithGroup(name string) slog.Handler {
 	return &TFHandler{
+		Level:  h.Level,
 		attrs:  h.attrs,
 		groups: append(h.groups, name),
 	}
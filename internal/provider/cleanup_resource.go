@@ -0,0 +1,166 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chainguard-dev/terraform-provider-imagetest/internal/docker"
+	"github.com/chainguard-dev/terraform-provider-imagetest/internal/log"
+	"github.com/chainguard-dev/terraform-provider-imagetest/internal/provider/framework"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var _ resource.Resource = &CleanupResource{}
+
+func NewCleanupResource() resource.Resource {
+	return &CleanupResource{WithTypeName: "cleanup"}
+}
+
+// CleanupResource removes the dangling docker resources left behind by
+// harnesses whose teardown was skipped.
+type CleanupResource struct {
+	framework.WithTypeName
+	framework.WithNoOpRead
+	framework.WithNoOpDelete
+}
+
+type CleanupResourceModel struct {
+	OlderThan  types.String `tfsdk:"older_than"`
+	Triggers   types.Map    `tfsdk:"triggers"`
+	DryRun     types.Bool   `tfsdk:"dry_run"`
+	Containers types.List   `tfsdk:"containers"`
+	Networks   types.List   `tfsdk:"networks"`
+	Volumes    types.List   `tfsdk:"volumes"`
+}
+
+func (r *CleanupResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Removes the dangling docker containers, networks, and volumes created by imagetest (labeled `dev.chainguard.imagetest=true`), such as those left behind when harness teardown is skipped. Resources created by the current provider run are never removed. The cleanup runs when the resource is created, and again only when its configuration changes, so use `triggers` to rerun it.",
+		Attributes: map[string]schema.Attribute{
+			"older_than": schema.StringAttribute{
+				Description: "Only remove resources created at least this long ago, as a duration such as 1h. This avoids removing the resources of runs that are still in progress, such as concurrent CI jobs sharing a docker daemon. Defaults to 1h.",
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("1h"),
+			},
+			"triggers": schema.MapAttribute{
+				Description: "Arbitrary values that rerun the cleanup when they change, such as a timestamp.",
+				Optional:    true,
+				ElementType: types.StringType,
+			},
+			"dry_run": schema.BoolAttribute{
+				Description: "Only report the resources that would be removed, without removing them.",
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+			},
+			"containers": schema.ListAttribute{
+				Description: "The IDs of the containers that were removed (or would be removed for a dry run).",
+				Computed:    true,
+				ElementType: types.StringType,
+			},
+			"networks": schema.ListAttribute{
+				Description: "The names of the networks that were removed (or would be removed for a dry run).",
+				Computed:    true,
+				ElementType: types.StringType,
+			},
+			"volumes": schema.ListAttribute{
+				Description: "The names of the volumes that were removed (or would be removed for a dry run).",
+				Computed:    true,
+				ElementType: types.StringType,
+			},
+		},
+	}
+}
+
+func (r *CleanupResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data CleanupResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(r.do(ctx, &data)...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *CleanupResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data CleanupResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(r.do(ctx, &data)...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *CleanupResource) do(ctx context.Context, data *CleanupResourceModel) (ds diag.Diagnostics) {
+	preq := &docker.PruneRequest{
+		DryRun: data.DryRun.ValueBool(),
+	}
+
+	if v := data.OlderThan.ValueString(); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			ds.AddError("invalid older_than", err.Error())
+			return ds
+		}
+		preq.OlderThan = d
+	}
+
+	// Always set the computed values, even if the cleanup fails
+	report := &docker.PruneReport{}
+	defer func() {
+		for _, l := range []struct {
+			dst  *types.List
+			vals []string
+		}{
+			{&data.Containers, report.Containers},
+			{&data.Networks, report.Networks},
+			{&data.Volumes, report.Volumes},
+		} {
+			v, diags := types.ListValueFrom(ctx, types.StringType, append([]string{}, l.vals...))
+			ds.Append(diags...)
+			*l.dst = v
+		}
+	}()
+
+	cli, err := docker.New()
+	if err != nil {
+		ds.AddError("failed to create docker client", err.Error())
+		return ds
+	}
+
+	pruned, err := cli.Prune(ctx, preq)
+	if pruned != nil {
+		report = pruned
+	}
+	if err != nil {
+		ds.AddError("failed to cleanup imagetest resources", err.Error())
+		return ds
+	}
+
+	log.Info(ctx, "cleaned up imagetest resources",
+		"dry_run", preq.DryRun,
+		"containers", len(report.Containers),
+		"networks", len(report.Networks),
+		"volumes", len(report.Volumes),
+	)
+
+	if preq.DryRun {
+		ds.AddWarning(
+			"imagetest cleanup dry run",
+			fmt.Sprintf("%d containers, %d networks, and %d volumes would be removed.",
+				len(report.Containers), len(report.Networks), len(report.Volumes)),
+		)
+	}
+
+	return ds
+}
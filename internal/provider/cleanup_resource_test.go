@@ -0,0 +1,37 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+func TestAccCleanupResource(t *testing.T) {
+	t.Parallel()
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testProviderWithRegistry(t, context.Background()),
+		Steps: []resource.TestStep{
+			{
+				// A dry run never removes anything, so this is safe to run
+				// alongside the other tests
+				Config: `
+resource "imagetest_cleanup" "test" {
+  dry_run    = true
+  older_than = "24h"
+
+  triggers = {
+    run = "1"
+  }
+}
+        `,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("imagetest_cleanup.test", "dry_run", "true"),
+					resource.TestCheckResourceAttrSet("imagetest_cleanup.test", "containers.#"),
+				),
+			},
+		},
+	})
+}
@@ -44,7 +44,7 @@ func (r *ContainerVolumeResource) Create(ctx context.Context, req resource.Creat
 		return
 	}
 
-	resp.Diagnostics.Append(r.create(ctx, req, harness)...)
+	resp.Diagnostics.Append(r.create(ctx, req, "volume", harness)...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
@@ -65,7 +65,7 @@ func (r *ContainerVolumeResource) Update(ctx context.Context, req resource.Updat
 		return
 	}
 
-	resp.Diagnostics.Append(r.update(ctx, req, harness)...)
+	resp.Diagnostics.Append(r.update(ctx, req, "volume", harness)...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
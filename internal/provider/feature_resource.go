@@ -3,9 +3,11 @@ package provider
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"time"
+	"unicode/utf8"
 
 	"github.com/chainguard-dev/terraform-provider-imagetest/internal/features"
 	"github.com/chainguard-dev/terraform-provider-imagetest/internal/harness"
@@ -14,6 +16,7 @@ import (
 	"github.com/chainguard-dev/terraform-provider-imagetest/internal/provider/framework"
 	"github.com/chainguard-dev/terraform-provider-imagetest/internal/skip"
 	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
@@ -29,6 +32,12 @@ import (
 const (
 	// TODO: Make the default feature timeout configurable?
 	defaultFeatureCreateTimeout = 15 * time.Minute
+
+	// maxStepOutputBytes is the amount of each step's output, from the end,
+	// kept in the step_outputs attribute.
+	maxStepOutputBytes = 4 * 1024
+
+	redactedStepOutput = "(sensitive output redacted)"
 )
 
 var _ resource.ResourceWithModifyPlan = &FeatureResource{}
@@ -58,21 +67,27 @@ type FeatureResourceModel struct {
 	Timeouts      timeouts.Value     `tfsdk:"timeouts"`
 	Skipped       types.String       `tfsdk:"skipped"`
 	WarnOnFailure types.Bool         `tfsdk:"warn_on_failure"`
+	StepOutputs   types.Map          `tfsdk:"step_outputs"`
+
+	SkipTeardown          types.Bool `tfsdk:"skip_teardown"`
+	SkipTeardownOnFailure types.Bool `tfsdk:"skip_teardown_on_failure"`
 
 	Harness FeatureHarnessResourceModel `tfsdk:"harness"`
 }
 
 type FeatureStepModel struct {
-	Name    types.String             `tfsdk:"name"`
-	Cmd     types.String             `tfsdk:"cmd"`
-	Workdir types.String             `tfsdk:"workdir"`
-	Retry   *FeatureStepBackoffModel `tfsdk:"retry"`
+	Name      types.String             `tfsdk:"name"`
+	Cmd       types.String             `tfsdk:"cmd"`
+	Workdir   types.String             `tfsdk:"workdir"`
+	Sensitive types.Bool               `tfsdk:"sensitive"`
+	Retry     *FeatureStepBackoffModel `tfsdk:"retry"`
 }
 
 type FeatureStepBackoffModel struct {
-	Attempts types.Int64   `tfsdk:"attempts"`
-	Delay    types.String  `tfsdk:"delay"`
-	Factor   types.Float64 `tfsdk:"factor"`
+	Attempts         types.Int64   `tfsdk:"attempts"`
+	Delay            types.String  `tfsdk:"delay"`
+	Factor           types.Float64 `tfsdk:"factor"`
+	RetryOnExitCodes []int64       `tfsdk:"retry_on_exit_codes"`
 }
 
 func (r *FeatureResource) Schema(ctx context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
@@ -111,6 +126,10 @@ func (r *FeatureResource) Schema(ctx context.Context, _ resource.SchemaRequest,
 								Description: "An optional working directory for the step to run in",
 								Optional:    true,
 							},
+							"sensitive": schema.BoolAttribute{
+								Description: "Whether the step's output is sensitive. Sensitive output is redacted from step_outputs.",
+								Optional:    true,
+							},
 							"retry": schema.SingleNestedAttribute{
 								Description: "Optional retry configuration for the step",
 								Optional:    true,
@@ -136,6 +155,10 @@ func (r *FeatureResource) Schema(ctx context.Context, _ resource.SchemaRequest,
 								Description: "An optional working directory for the step to run in",
 								Optional:    true,
 							},
+							"sensitive": schema.BoolAttribute{
+								Description: "Whether the step's output is sensitive. Sensitive output is redacted from step_outputs.",
+								Optional:    true,
+							},
 							"retry": schema.SingleNestedAttribute{
 								Description: "Optional retry configuration for the step",
 								Optional:    true,
@@ -161,6 +184,10 @@ func (r *FeatureResource) Schema(ctx context.Context, _ resource.SchemaRequest,
 								Description: "An optional working directory for the step to run in",
 								Optional:    true,
 							},
+							"sensitive": schema.BoolAttribute{
+								Description: "Whether the step's output is sensitive. Sensitive output is redacted from step_outputs.",
+								Optional:    true,
+							},
 							"retry": schema.SingleNestedAttribute{
 								Description: "Optional retry configuration for the step",
 								Optional:    true,
@@ -187,6 +214,19 @@ func (r *FeatureResource) Schema(ctx context.Context, _ resource.SchemaRequest,
 					Computed:    true,
 					Default:     booldefault.StaticBool(false),
 				},
+				"skip_teardown": schema.BoolAttribute{
+					Description: "Skips the teardown of the harness after this feature to allow debugging it. A harness shared by several features is kept if any of them skips its teardown. The provider's skip_teardown (or IMAGETEST_SKIP_TEARDOWN) skips teardown for every feature regardless of this setting.",
+					Optional:    true,
+				},
+				"skip_teardown_on_failure": schema.BoolAttribute{
+					Description: "Skips the teardown of the harness only when this feature fails, to allow debugging the failure. A harness shared by several features is kept if any of them fails with this set.",
+					Optional:    true,
+				},
+				"step_outputs": schema.MapAttribute{
+					Description: "The combined output of each step that ran, keyed by its block and position, followed by its name when set, such as before[0] or steps[1].build. Only the last 4KiB of each step's output is kept, and the output of sensitive steps is redacted.",
+					Computed:    true,
+					ElementType: types.StringType,
+				},
 			},
 		),
 	}
@@ -266,16 +306,16 @@ func (r *FeatureResource) Create(ctx context.Context, req resource.CreateRequest
 		return
 	}
 
-	// TODO: Move this around if/when we start storing test output in the state
+	resp.Diagnostics.Append(r.do(ctx, &data)...)
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
-
-	resp.Diagnostics.Append(r.do(ctx, data)...)
-	if resp.Diagnostics.HasError() {
-		return
-	}
 }
 
-func (r *FeatureResource) do(ctx context.Context, data FeatureResourceModel) (ds diag.Diagnostics) {
+func (r *FeatureResource) do(ctx context.Context, data *FeatureResourceModel) (ds diag.Diagnostics) {
+	outputs := make(stepOutputs)
+	defer func() {
+		data.StepOutputs = outputs.value()
+	}()
+
 	if data.Skipped.ValueString() != "" {
 		ds.AddWarning(
 			fmt.Sprintf("skipping feature %s [%s]", data.Name.ValueString(), data.Id.ValueString()),
@@ -309,8 +349,9 @@ func (r *FeatureResource) do(ctx context.Context, data FeatureResourceModel) (ds
 		return ds
 	}
 
+	var failed bool
 	defer func() {
-		ds.Append(r.teardown(ctx, data, harness)...)
+		ds.Append(r.teardown(ctx, *data, harness, failed)...)
 	}()
 
 	fopts := []features.Option{
@@ -319,22 +360,22 @@ func (r *FeatureResource) do(ctx context.Context, data FeatureResourceModel) (ds
 
 	feat := features.New(data.Name.ValueString(), fopts...)
 
-	for _, before := range data.Before {
-		if err := r.step(feat, harness, before, features.Before); err != nil {
+	for i, before := range data.Before {
+		if err := r.step(feat, harness, before, features.Before, outputs.recorder("before", i, before)); err != nil {
 			ds.AddError("failed to create before step", err.Error())
 			return ds
 		}
 	}
 
-	for _, after := range data.After {
-		if err := r.step(feat, harness, after, features.After); err != nil {
+	for i, after := range data.After {
+		if err := r.step(feat, harness, after, features.After, outputs.recorder("after", i, after)); err != nil {
 			ds.AddError("failed to create after step", err.Error())
 			return ds
 		}
 	}
 
-	for _, assess := range data.Steps {
-		if err := r.step(feat, harness, assess, features.Assessment); err != nil {
+	for i, assess := range data.Steps {
+		if err := r.step(feat, harness, assess, features.Assessment, outputs.recorder("steps", i, assess)); err != nil {
 			ds.AddError("failed to create assessment step", err.Error())
 			return ds
 		}
@@ -343,6 +384,7 @@ func (r *FeatureResource) do(ctx context.Context, data FeatureResourceModel) (ds
 	log.Info(ctx, "testing feature against harness")
 
 	if err = feat.Test(ctx); err != nil {
+		failed = true
 		if data.WarnOnFailure.ValueBool() {
 			ds.AddWarning(
 				fmt.Sprintf("failed to test feature: %s", feat.Name),
@@ -367,16 +409,11 @@ func (r *FeatureResource) Update(ctx context.Context, req resource.UpdateRequest
 		return
 	}
 
-	// TODO: Move this around if/when we start storing test output in the state
+	resp.Diagnostics.Append(r.do(ctx, &data)...)
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
-
-	resp.Diagnostics.Append(r.do(ctx, data)...)
-	if resp.Diagnostics.HasError() {
-		return
-	}
 }
 
-func (r *FeatureResource) step(feat *features.Feature, h harness.Harness, data FeatureStepModel, level features.Level) error {
+func (r *FeatureResource) step(feat *features.Feature, h harness.Harness, data FeatureStepModel, level features.Level, record func(string)) error {
 	fn := features.StepFn(func(ctx context.Context) error {
 		ctx = log.With(ctx,
 			"step_name", data.Name.ValueString(),
@@ -400,6 +437,12 @@ func (r *FeatureResource) step(feat *features.Feature, h harness.Harness, data F
 			"output", bufall.String(),
 		)
 
+		if data.Sensitive.ValueBool() {
+			record(redactedStepOutput)
+		} else {
+			record(tailOutput(bufall.String(), maxStepOutputBytes))
+		}
+
 		if err != nil {
 			if rerr, ok := err.(*harness.RunError); ok {
 				log.Warn(ctx, "feature step failed with non-zero exit code",
@@ -421,14 +464,14 @@ func (r *FeatureResource) step(feat *features.Feature, h harness.Harness, data F
 		if err != nil {
 			return fmt.Errorf("failed to parse step retry duration: %w", err)
 		}
-		sopts = append(sopts, features.StepWithRetry(wait.Backoff{
+		sopts = append(sopts, features.StepWithRetryIf(wait.Backoff{
 			Duration: duration,
 			Steps:    int(data.Retry.Attempts.ValueInt64()),
 			Factor:   data.Retry.Factor.ValueFloat64(),
 			// Set a small default value just as a best practice, even though this
 			// isn't exposed, in reality it will never be noticed
 			Jitter: 0.05,
-		}))
+		}, retryOnExitCodes(data.Retry.RetryOnExitCodes)))
 	}
 
 	switch level {
@@ -443,33 +486,50 @@ func (r *FeatureResource) step(feat *features.Feature, h harness.Harness, data F
 	return nil
 }
 
-func (r *FeatureResource) teardown(ctx context.Context, data FeatureResourceModel, h harness.Harness) diag.Diagnostics {
+func (r *FeatureResource) teardown(ctx context.Context, data FeatureResourceModel, h harness.Harness, failed bool) diag.Diagnostics {
 	inv, ok := r.store.inv.Get(data.Harness.Inventory.Seed.ValueString())
 	if !ok {
 		return []diag.Diagnostic{diag.NewErrorDiagnostic("failed to get inventory", fmt.Sprintf("inventory [%s] does not exist", data.Harness.Inventory.Seed.ValueString()))}
 	}
 
-	if err := inv.RemoveFeature(ctx, data.Harness.Id.ValueString(), data.Id.ValueString()); err != nil {
+	// Record this feature's teardown intent on the harness, since the harness
+	// is only torn down once its last feature is removed, which may not be
+	// this one
+	var skipReason string
+	if skip, reason := r.skipTeardown(data, failed); skip {
+		skipReason = fmt.Sprintf("%s on feature [%s]", reason, data.Name.ValueString())
+	}
+
+	if err := inv.RemoveFeature(ctx, data.Harness.Id.ValueString(), data.Id.ValueString(), skipReason); err != nil {
 		return []diag.Diagnostic{diag.NewErrorDiagnostic("failed to remove feature from inventory", err.Error())}
 	}
 
-	remaining, err := inv.GetFeatures(ctx, data.Harness.Id.ValueString())
+	hinv, err := inv.GetHarness(ctx, data.Harness.Id.ValueString())
 	if err != nil {
 		return []diag.Diagnostic{diag.NewErrorDiagnostic("failed to list features", err.Error())}
 	}
 
-	if len(remaining) == 0 {
+	if len(hinv.Features) == 0 {
 		log.Debug(ctx, "no more features remain in inventory, removing harness")
 
 		if err := inv.RemoveHarness(ctx, data.Harness.Id.ValueString()); err != nil {
 			return []diag.Diagnostic{diag.NewErrorDiagnostic("failed to remove harness from inventory", err.Error())}
 		}
 
+		// Collect artifacts before deciding on teardown, so they are collected
+		// even when teardown is skipped
+		var ds diag.Diagnostics
+		if c, ok := h.(harness.ArtifactCollector); ok {
+			if err := c.CollectArtifacts(ctx); err != nil {
+				ds.AddWarning("failed to collect harness artifacts", err.Error())
+			}
+		}
+
 		// Destroy the harness...
-		if r.store.SkipTeardown() {
-			return []diag.Diagnostic{
+		if hinv.SkipTeardown != "" {
+			return append(ds,
 				diag.NewWarningDiagnostic(
-					fmt.Sprintf("teardown for harness [%s] is skipped because IMAGETEST_SKIP_TEARDOWN is set", data.Harness.Id.ValueString()),
+					fmt.Sprintf("teardown for harness [%s] is skipped because %s", data.Harness.Id.ValueString(), hinv.SkipTeardown),
 					fmt.Sprintf(`There are dangling resources that will require manual cleanup.
 
 To remove the resources specific to this harness, run the following:
@@ -483,17 +543,95 @@ To cleanup all resources owned by imagetest, run the following:
   docker system prune --volumes --all
 
 If you are regularly skipping the harness teardown, its recommended you run the imagetest cleanup regularly. Too many dangling resources *will* cause problems.`, data.Harness.Id.ValueString())),
-			}
+			)
 		}
 
 		if err := h.Destroy(ctx); err != nil {
-			return []diag.Diagnostic{diag.NewWarningDiagnostic("failed to destroy harness", err.Error())}
+			return append(ds, diag.NewWarningDiagnostic("failed to destroy harness", err.Error()))
 		}
+
+		return ds
 	}
 
 	return diag.Diagnostics{}
 }
 
+// skipTeardown reports whether the harness teardown should be skipped, and
+// why. The provider level setting applies to every feature, and takes
+// precedence over the feature's own settings.
+func (r *FeatureResource) skipTeardown(data FeatureResourceModel, failed bool) (bool, string) {
+	switch {
+	case r.store.SkipTeardown():
+		return true, "IMAGETEST_SKIP_TEARDOWN is set"
+	case data.SkipTeardown.ValueBool():
+		return true, "skip_teardown is set"
+	case failed && data.SkipTeardownOnFailure.ValueBool():
+		return true, "the feature failed and skip_teardown_on_failure is set"
+	}
+	return false, ""
+}
+
+// stepOutputs collects the output of each step that ran, keyed by step.
+type stepOutputs map[string]string
+
+// recorder returns a func that records the output of the step. Steps are
+// keyed by their position within their block, so steps sharing a name don't
+// collide, followed by their name when set.
+func (o stepOutputs) recorder(block string, i int, step FeatureStepModel) func(string) {
+	k := fmt.Sprintf("%s[%d]", block, i)
+	if name := step.Name.ValueString(); name != "" {
+		k += "." + name
+	}
+
+	return func(out string) {
+		o[k] = out
+	}
+}
+
+func (o stepOutputs) value() types.Map {
+	vals := make(map[string]attr.Value, len(o))
+	for k, v := range o {
+		vals[k] = types.StringValue(v)
+	}
+	return types.MapValueMust(types.StringType, vals)
+}
+
+// tailOutput returns the last n bytes of out, without splitting a rune.
+func tailOutput(out string, n int) string {
+	if len(out) <= n {
+		return out
+	}
+
+	i := len(out) - n
+	for i < len(out) && !utf8.RuneStart(out[i]) {
+		i++
+	}
+	return out[i:]
+}
+
+// retryOnExitCodes returns a retryable func that only retries steps that
+// failed with one of the given exit codes. When no codes are given, every
+// failure is retried.
+func retryOnExitCodes(codes []int64) func(error) bool {
+	if len(codes) == 0 {
+		return nil
+	}
+
+	return func(err error) bool {
+		var rerr *harness.RunError
+		if !errors.As(err, &rerr) {
+			return false
+		}
+
+		for _, code := range codes {
+			if int64(rerr.ExitCode) == code {
+				return true
+			}
+		}
+		return false
+	}
+}
+
 func addFeatureStepBackoffSchemaAttributes() map[string]schema.Attribute {
 	return map[string]schema.Attribute{
 		"attempts": schema.Int64Attribute{
@@ -512,6 +650,11 @@ func addFeatureStepBackoffSchemaAttributes() map[string]schema.Attribute {
 			Computed:    true,
 			Default:     float64default.StaticFloat64(1.0),
 		},
+		"retry_on_exit_codes": schema.ListAttribute{
+			Description: "Only retry the step when it exits with one of these exit codes. Any other failure is terminal and is not retried. Defaults to retrying on any non-zero exit code.",
+			Optional:    true,
+			ElementType: types.Int64Type,
+		},
 	}
 }
 
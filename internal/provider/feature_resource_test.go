@@ -3,9 +3,15 @@ package provider
 import (
 	"context"
 	"regexp"
+	"strconv"
 	"testing"
 
+	"github.com/chainguard-dev/terraform-provider-imagetest/internal/harness"
+	"github.com/chainguard-dev/terraform-provider-imagetest/internal/inventory"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAccFeatureResource(t *testing.T) {
@@ -123,6 +129,256 @@ resource "imagetest_feature" "test" {
 	})
 }
 
+func TestAccFeatureResourceRetryOnExitCodes(t *testing.T) {
+	t.Parallel()
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testProviderWithRegistry(t, context.Background()),
+		Steps: []resource.TestStep{
+			{
+				ExpectNonEmptyPlan: true,
+				Config: `
+data "imagetest_inventory" "this" {}
+
+resource "imagetest_harness_docker" "test" {
+  name = "test"
+  inventory = data.imagetest_inventory.this
+}
+
+resource "imagetest_feature" "terminal" {
+  name = "Terminal"
+  description = "A terminal exit code is not retried"
+  harness = imagetest_harness_docker.test
+  warn_on_failure = true
+  steps = [
+    {
+      name = "fail with a terminal exit code"
+      cmd = <<EOF
+        file=/tmp/feature_terminal_test
+        echo $(( $(cat $file 2>/dev/null || echo 0) + 1 )) > $file
+        exit 3
+      EOF
+      retry = {
+        attempts = 3
+        delay = "0s"
+        retry_on_exit_codes = [1]
+      }
+    },
+  ]
+}
+
+resource "imagetest_feature" "assert" {
+  name = "Assert"
+  description = "Assert the terminal step only ran once"
+  harness = imagetest_harness_docker.test
+  depends_on = [imagetest_feature.terminal]
+  steps = [
+    {
+      name = "assert"
+      cmd = <<EOF
+        if [ $(cat /tmp/feature_terminal_test) -ne 1 ]; then
+          echo "Expected 1 attempt, got $(cat /tmp/feature_terminal_test)"
+          exit 1
+        fi
+      EOF
+    },
+  ]
+}
+        `,
+			},
+		},
+	})
+}
+
+func TestAccFeatureResourceStepOutputs(t *testing.T) {
+	t.Parallel()
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testProviderWithRegistry(t, context.Background()),
+		Steps: []resource.TestStep{
+			{
+				ExpectNonEmptyPlan: true,
+				Config: `
+data "imagetest_inventory" "this" {}
+
+resource "imagetest_harness_docker" "test" {
+  name = "test"
+  inventory = data.imagetest_inventory.this
+}
+
+resource "imagetest_feature" "test" {
+  name = "Outputs"
+  description = "Step outputs are captured"
+  harness = imagetest_harness_docker.test
+  before = [
+    {
+      cmd = "echo before"
+    },
+  ]
+  steps = [
+    {
+      name = "combined"
+      cmd = "echo out; echo err >&2"
+    },
+    {
+      name = "secret"
+      cmd = "echo hunter2"
+      sensitive = true
+    },
+    {
+      name = "combined"
+      cmd = "echo again"
+    },
+  ]
+}
+        `,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("imagetest_feature.test", "step_outputs.%", "4"),
+					resource.TestCheckResourceAttr("imagetest_feature.test", "step_outputs.before[0]", "before\n"),
+					resource.TestCheckResourceAttr("imagetest_feature.test", "step_outputs.steps[0].combined", "out\nerr\n"),
+					resource.TestCheckResourceAttr("imagetest_feature.test", "step_outputs.steps[1].secret", "(sensitive output redacted)"),
+					resource.TestCheckResourceAttr("imagetest_feature.test", "step_outputs.steps[2].combined", "again\n"),
+				),
+			},
+		},
+	})
+}
+
+func TestTailOutput(t *testing.T) {
+	require.Equal(t, "hello", tailOutput("hello", 10))
+	require.Equal(t, "llo", tailOutput("hello", 3))
+	// Multi-byte runes are never split
+	require.Equal(t, "é!", tailOutput("héé!", 4))
+}
+
+func TestFeatureResourceSkipTeardown(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		global     bool
+		skip       bool
+		onFailure  bool
+		failed     bool
+		wantSkip   bool
+		wantReason string
+	}{
+		{name: "default"},
+		{name: "default failed", failed: true},
+		{name: "global", global: true, wantSkip: true, wantReason: "IMAGETEST_SKIP_TEARDOWN is set"},
+		{name: "global overrides resource", global: true, skip: true, onFailure: true, failed: true, wantSkip: true, wantReason: "IMAGETEST_SKIP_TEARDOWN is set"},
+		{name: "resource", skip: true, wantSkip: true, wantReason: "skip_teardown is set"},
+		{name: "on failure passed", onFailure: true},
+		{name: "on failure failed", onFailure: true, failed: true, wantSkip: true, wantReason: "the feature failed and skip_teardown_on_failure is set"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &FeatureResource{store: &ProviderStore{skipTeardown: tc.global}}
+			skip, reason := r.skipTeardown(FeatureResourceModel{
+				SkipTeardown:          types.BoolValue(tc.skip),
+				SkipTeardownOnFailure: types.BoolValue(tc.onFailure),
+			}, tc.failed)
+			require.Equal(t, tc.wantSkip, skip)
+			require.Equal(t, tc.wantReason, reason)
+		})
+	}
+}
+
+type teardownHarness struct {
+	harness.Harness
+	destroyed bool
+}
+
+func (h *teardownHarness) Destroy(context.Context) error {
+	h.destroyed = true
+	return nil
+}
+
+func TestFeatureResourceTeardownSharedHarness(t *testing.T) {
+	type feature struct {
+		skip      bool
+		onFailure bool
+		failed    bool
+	}
+
+	for _, tc := range []struct {
+		name string
+		// features are torn down in order
+		features    []feature
+		wantDestroy bool
+		wantWarning string
+	}{
+		{
+			name:        "none skipped",
+			features:    []feature{{}, {failed: true}},
+			wantDestroy: true,
+		},
+		{
+			name:        "first failed",
+			features:    []feature{{onFailure: true, failed: true}, {}},
+			wantWarning: "the feature failed and skip_teardown_on_failure is set on feature [0]",
+		},
+		{
+			name:        "first skipped",
+			features:    []feature{{skip: true}, {onFailure: true}},
+			wantWarning: "skip_teardown is set on feature [0]",
+		},
+		{
+			name:        "last skipped",
+			features:    []feature{{}, {skip: true}},
+			wantWarning: "skip_teardown is set on feature [1]",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			seed := t.TempDir()
+
+			inv, err := inventory.NewInventory(seed)
+			require.NoError(t, err)
+			require.NoError(t, inv.AddHarness(ctx, "harness"))
+
+			r := &FeatureResource{store: &ProviderStore{
+				inv: &mmap[string, *inventory.Inventory]{
+					store: map[string]*inventory.Inventory{seed: inv},
+				},
+			}}
+			h := &teardownHarness{}
+
+			var data []FeatureResourceModel
+			for i, f := range tc.features {
+				id := strconv.Itoa(i)
+				require.NoError(t, inv.AddFeature(ctx, "harness", inventory.Feature{Id: id}))
+				data = append(data, FeatureResourceModel{
+					Id:                    types.StringValue(id),
+					Name:                  types.StringValue(id),
+					SkipTeardown:          types.BoolValue(f.skip),
+					SkipTeardownOnFailure: types.BoolValue(f.onFailure),
+					Harness: FeatureHarnessResourceModel{
+						Id:        types.StringValue("harness"),
+						Inventory: InventoryDataSourceModel{Seed: types.StringValue(seed)},
+					},
+				})
+			}
+
+			var ds diag.Diagnostics
+			for i, f := range tc.features {
+				ds = r.teardown(ctx, data[i], h, f.failed)
+				require.False(t, ds.HasError(), "%v", ds)
+				if i < len(tc.features)-1 {
+					require.False(t, h.destroyed, "harness destroyed before its last feature")
+				}
+			}
+
+			require.Equal(t, tc.wantDestroy, h.destroyed)
+			if tc.wantWarning == "" {
+				require.Empty(t, ds)
+				return
+			}
+			require.Len(t, ds, 1)
+			require.Contains(t, ds[0].Summary(), tc.wantWarning)
+		})
+	}
+}
+
 // TestAccFeatureResourceUpdate tests that this provider works with Update()
 // requests as well. This also hits the base_harness path, where all the
 // harness update logic is located.
@@ -332,3 +588,17 @@ resource "imagetest_feature" "exclude2" {
 		},
 	})
 }
+
+func TestStepOutputs(t *testing.T) {
+	outputs := make(stepOutputs)
+	outputs.recorder("before", 0, FeatureStepModel{})("setup")
+	outputs.recorder("steps", 0, FeatureStepModel{Name: types.StringValue("check")})("first")
+	outputs.recorder("steps", 1, FeatureStepModel{Name: types.StringValue("check")})("second")
+
+	// Steps sharing a name don't overwrite each other
+	require.Equal(t, stepOutputs{
+		"before[0]":      "setup",
+		"steps[0].check": "first",
+		"steps[1].check": "second",
+	}, outputs)
+}
@@ -25,6 +25,9 @@ const (
 	defaultHarnessCreateTimeout = 5 * time.Minute
 )
 
+// harnessKinds are the kinds of harnesses whose creation can be serialized.
+var harnessKinds = []string{"docker", "k3s", "pterraform"}
+
 // BaseHarnessResource provides common methods for all BaseHarnessResource
 // implementations.
 type BaseHarnessResource struct {
@@ -185,7 +188,7 @@ func (r *BaseHarnessResource) ModifyPlan(ctx context.Context, req resource.Modif
 	}
 }
 
-func (r *BaseHarnessResource) create(ctx context.Context, req resource.CreateRequest, harness harness.Harness) diag.Diagnostics {
+func (r *BaseHarnessResource) create(ctx context.Context, req resource.CreateRequest, kind string, harness harness.Harness) diag.Diagnostics {
 	return r.do(
 		ctx,
 		framework.CreateOrUpdateRequest{
@@ -193,11 +196,12 @@ func (r *BaseHarnessResource) create(ctx context.Context, req resource.CreateReq
 			Plan:         req.Plan,
 			ProviderMeta: req.ProviderMeta,
 		},
+		kind,
 		harness,
 	)
 }
 
-func (r *BaseHarnessResource) update(ctx context.Context, req resource.UpdateRequest, harness harness.Harness) diag.Diagnostics {
+func (r *BaseHarnessResource) update(ctx context.Context, req resource.UpdateRequest, kind string, harness harness.Harness) diag.Diagnostics {
 	return r.do(
 		ctx,
 		framework.CreateOrUpdateRequest{
@@ -205,11 +209,12 @@ func (r *BaseHarnessResource) update(ctx context.Context, req resource.UpdateReq
 			Plan:         req.Plan,
 			ProviderMeta: req.ProviderMeta,
 		},
+		kind,
 		harness,
 	)
 }
 
-func (r *BaseHarnessResource) do(ctx context.Context, req framework.CreateOrUpdateRequest, harness harness.Harness) diag.Diagnostics {
+func (r *BaseHarnessResource) do(ctx context.Context, req framework.CreateOrUpdateRequest, kind string, harness harness.Harness) diag.Diagnostics {
 	var (
 		data  BaseHarnessResourceModel
 		diags diag.Diagnostics
@@ -262,6 +267,13 @@ func (r *BaseHarnessResource) do(ctx context.Context, req framework.CreateOrUpda
 
 	r.store.harnesses.Set(data.Id.ValueString(), harness)
 
+	// Time spent waiting on other harnesses counts towards the create timeout
+	unlock, err := r.store.lockHarness(ctx, kind)
+	if err != nil {
+		return []diag.Diagnostic{diag.NewErrorDiagnostic("failed to create harness", err.Error())}
+	}
+	defer unlock()
+
 	if err := harness.Create(ctx); err != nil {
 		return []diag.Diagnostic{diag.NewErrorDiagnostic("failed to create harness", err.Error())}
 	}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/chainguard-dev/terraform-provider-imagetest/internal/bundler"
 	client "github.com/chainguard-dev/terraform-provider-imagetest/internal/docker"
@@ -12,6 +13,7 @@ import (
 	"github.com/chainguard-dev/terraform-provider-imagetest/internal/harness/docker"
 	"github.com/chainguard-dev/terraform-provider-imagetest/internal/log"
 	"github.com/chainguard-dev/terraform-provider-imagetest/internal/provider/framework"
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
@@ -50,11 +52,66 @@ type HarnessDockerResourceModel struct {
 	Packages     []string                               `tfsdk:"packages"`
 	Repositories []string                               `tfsdk:"repositories"`
 	Keyrings     []string                               `tfsdk:"keyrings"`
+	CacheBase    types.Bool                             `tfsdk:"cache_base"`
+	Gpus         types.String                           `tfsdk:"gpus"`
+	User         types.String                           `tfsdk:"user"`
+	ExtraHosts   []string                               `tfsdk:"extra_hosts"`
+	Artifacts    *HarnessDockerArtifactsModel           `tfsdk:"artifacts"`
+	HealthCheck  *HarnessDockerHealthCheckModel         `tfsdk:"health_check"`
 	Networks     map[string]ContainerNetworkModel       `tfsdk:"networks"`
 	Registries   map[string]DockerRegistryResourceModel `tfsdk:"registries"`
 	Resources    *ContainerResources                    `tfsdk:"resources"`
 }
 
+type HarnessDockerArtifactsModel struct {
+	Directory types.String `tfsdk:"directory"`
+	Paths     []string     `tfsdk:"paths"`
+}
+
+type HarnessDockerHealthCheckModel struct {
+	Test        []string     `tfsdk:"test"`
+	Interval    types.String `tfsdk:"interval"`
+	Timeout     types.String `tfsdk:"timeout"`
+	StartPeriod types.String `tfsdk:"start_period"`
+	Retries     types.Int64  `tfsdk:"retries"`
+}
+
+// healthConfig overlays the configured health check on the harness default.
+// A test of ["NONE"] disables the health check entirely.
+func (m *HarnessDockerHealthCheckModel) healthConfig() (*container.HealthConfig, error) {
+	if len(m.Test) == 1 && m.Test[0] == "NONE" {
+		return nil, nil
+	}
+
+	hc := docker.DefaultHealthCheck()
+	hc.Test = m.Test
+
+	for _, d := range []struct {
+		name  string
+		value types.String
+		into  *time.Duration
+	}{
+		{"interval", m.Interval, &hc.Interval},
+		{"timeout", m.Timeout, &hc.Timeout},
+		{"start_period", m.StartPeriod, &hc.StartPeriod},
+	} {
+		if d.value.IsNull() || d.value.IsUnknown() {
+			continue
+		}
+		v, err := time.ParseDuration(d.value.ValueString())
+		if err != nil {
+			return nil, fmt.Errorf("parsing health check %s: %w", d.name, err)
+		}
+		*d.into = v
+	}
+
+	if !m.Retries.IsNull() && !m.Retries.IsUnknown() {
+		hc.Retries = int(m.Retries.ValueInt64())
+	}
+
+	return hc, nil
+}
+
 type DockerRegistryResourceModel struct {
 	Auth *RegistryResourceAuthModel `tfsdk:"auth"`
 }
@@ -76,7 +133,7 @@ func (r *HarnessDockerResource) Create(ctx context.Context, req resource.CreateR
 		return
 	}
 
-	resp.Diagnostics.Append(r.create(ctx, req, harness)...)
+	resp.Diagnostics.Append(r.create(ctx, req, "docker", harness)...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
@@ -99,7 +156,7 @@ func (r *HarnessDockerResource) Update(ctx context.Context, req resource.UpdateR
 		return
 	}
 
-	resp.Diagnostics.Append(r.update(ctx, req, harness)...)
+	resp.Diagnostics.Append(r.update(ctx, req, "docker", harness)...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
@@ -110,6 +167,7 @@ func (r *HarnessDockerResource) harness(ctx context.Context, data *HarnessDocker
 
 	opts := []docker.Option{
 		docker.WithName(data.Id.ValueString()),
+		docker.WithKeychain(r.store.keychain),
 	}
 
 	mounts := make([]ContainerMountModel, 0)
@@ -143,6 +201,30 @@ func (r *HarnessDockerResource) harness(ctx context.Context, data *HarnessDocker
 	}
 	opts = append(opts, docker.WithVolumes(volumes...))
 
+	if !data.Gpus.IsNull() {
+		opts = append(opts, docker.WithGPUs(data.Gpus.ValueString()))
+	}
+
+	if len(data.ExtraHosts) > 0 {
+		opts = append(opts, docker.WithExtraHosts(data.ExtraHosts...))
+	}
+
+	if !data.User.IsNull() {
+		opts = append(opts, docker.WithUser(data.User.ValueString()))
+	}
+
+	if data.Artifacts != nil {
+		opts = append(opts, docker.WithArtifacts(data.Artifacts.Directory.ValueString(), data.Artifacts.Paths...))
+	}
+
+	if data.HealthCheck != nil {
+		hc, err := data.HealthCheck.healthConfig()
+		if err != nil {
+			return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("invalid health check", err.Error())}
+		}
+		opts = append(opts, docker.WithHealthCheck(hc))
+	}
+
 	if res := data.Resources; res != nil {
 		resources, err := ParseResources(res)
 		if err != nil {
@@ -257,7 +339,13 @@ func (r *HarnessDockerResource) bundler(data *HarnessDockerResourceModel) (bundl
 			return nil, fmt.Errorf("invalid reference: %w", err)
 		}
 
+		desc, err := r.store.Descriptor(ref)
+		if err != nil {
+			return nil, err
+		}
+
 		return bundler.NewAppender(ref,
+			bundler.AppenderWithDescriptor(desc),
 			bundler.AppenderWithRemoteOptions(r.store.ropts...),
 		)
 	}
@@ -269,6 +357,7 @@ func (r *HarnessDockerResource) bundler(data *HarnessDockerResourceModel) (bundl
 		bundler.ApkoWithPackages(data.Packages...),
 		bundler.ApkoWithRepositories(data.Repositories...),
 		bundler.ApkoWithKeyrings(data.Keyrings...),
+		bundler.ApkoWithCache(data.CacheBase.ValueBool()),
 	}
 
 	if p := r.store.providerResourceData.Sandbox; p != nil {
@@ -289,7 +378,7 @@ func (r *HarnessDockerResource) Schema(ctx context.Context, _ resource.SchemaReq
 			r.schemaAttributes(ctx),
 			map[string]schema.Attribute{
 				"image": schema.StringAttribute{
-					Description: "The full image reference to use for the container.",
+					Description: "The full image reference to use for the container. A custom image must include the docker cli for the default health check to pass, or override it with health_check.",
 					Optional:    true,
 				},
 				"packages": schema.ListAttribute{
@@ -307,6 +396,65 @@ func (r *HarnessDockerResource) Schema(ctx context.Context, _ resource.SchemaReq
 					Optional:    true,
 					ElementType: types.StringType,
 				},
+				"cache_base": schema.BoolAttribute{
+					Description: "Reuse a previously built harness image when the packages, repositories, and keyrings (including provider level sandbox extras) are unchanged, instead of rebuilding it with apko. Has no effect when image is set.",
+					Optional:    true,
+				},
+				"gpus": schema.StringAttribute{
+					Description: "The nvidia GPUs to expose to the harness container, either \"all\" or a number of GPUs. The host must have the nvidia container toolkit installed.",
+					Optional:    true,
+				},
+				"extra_hosts": schema.ListAttribute{
+					Description: "Additional host:ip entries to add to the container's /etc/hosts. The ip may be \"host-gateway\" to resolve to the host.",
+					Optional:    true,
+					ElementType: types.StringType,
+				},
+				"user": schema.StringAttribute{
+					Description: "The user (uid[:gid]) to run steps as. Defaults to the harness container's user.",
+					Optional:    true,
+				},
+				"artifacts": schema.SingleNestedAttribute{
+					Description: "Files and directories to copy out of the harness container once all of its features have run. Artifacts are copied even when teardown is skipped.",
+					Optional:    true,
+					Attributes: map[string]schema.Attribute{
+						"directory": schema.StringAttribute{
+							Description: "The relative or absolute path on the host to copy the artifacts into.",
+							Required:    true,
+						},
+						"paths": schema.ListAttribute{
+							Description: "The absolute paths in the container to copy. Directories are copied recursively.",
+							Required:    true,
+							ElementType: types.StringType,
+						},
+					},
+				},
+				"health_check": schema.SingleNestedAttribute{
+					Description: "Overrides the health check the harness container must pass before any steps run. By default the harness waits for `docker info` to succeed, which requires the docker cli in the harness image. Unset fields keep their defaults.",
+					Optional:    true,
+					Attributes: map[string]schema.Attribute{
+						"test": schema.ListAttribute{
+							Description: "The health check command in docker's HEALTHCHECK form, such as [\"CMD\", \"docker\", \"info\"] or [\"CMD-SHELL\", \"docker info\"]. Set to [\"NONE\"] to disable the health check, making the harness ready as soon as its container is running.",
+							Required:    true,
+							ElementType: types.StringType,
+						},
+						"interval": schema.StringAttribute{
+							Description: "The time to wait between checks, as a duration string. Defaults to 1s.",
+							Optional:    true,
+						},
+						"timeout": schema.StringAttribute{
+							Description: "The time a single check may take before it is considered failed, as a duration string. Defaults to 5s.",
+							Optional:    true,
+						},
+						"start_period": schema.StringAttribute{
+							Description: "The time given to the container to start before failing checks count towards retries, as a duration string. Defaults to 0s.",
+							Optional:    true,
+						},
+						"retries": schema.Int64Attribute{
+							Description: "The number of consecutive failed checks before the container is considered unhealthy. Defaults to 5.",
+							Optional:    true,
+						},
+					},
+				},
 				"privileged": schema.BoolAttribute{
 					Optional: true,
 					Computed: true,
@@ -4,8 +4,12 @@ import (
 	"context"
 	"regexp"
 	"testing"
+	"time"
 
+	"github.com/docker/docker/api/types/container"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/stretchr/testify/require"
 )
 
 func TestHarnessDockerResource(t *testing.T) {
@@ -377,3 +381,64 @@ resource "imagetest_feature" "test" {
 		})
 	}
 }
+
+func TestHarnessDockerHealthCheck(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		model   HarnessDockerHealthCheckModel
+		want    *container.HealthConfig
+		wantErr string
+	}{
+		{
+			name:  "defaults",
+			model: HarnessDockerHealthCheckModel{Test: []string{"CMD", "true"}},
+			want: &container.HealthConfig{
+				Test:          []string{"CMD", "true"},
+				Interval:      1 * time.Second,
+				Timeout:       5 * time.Second,
+				Retries:       5,
+				StartInterval: 1 * time.Second,
+			},
+		},
+		{
+			name: "overrides",
+			model: HarnessDockerHealthCheckModel{
+				Test:        []string{"CMD-SHELL", "docker info"},
+				Interval:    types.StringValue("2s"),
+				Timeout:     types.StringValue("10s"),
+				StartPeriod: types.StringValue("1m"),
+				Retries:     types.Int64Value(3),
+			},
+			want: &container.HealthConfig{
+				Test:          []string{"CMD-SHELL", "docker info"},
+				Interval:      2 * time.Second,
+				Timeout:       10 * time.Second,
+				StartPeriod:   1 * time.Minute,
+				Retries:       3,
+				StartInterval: 1 * time.Second,
+			},
+		},
+		{
+			name:  "disabled",
+			model: HarnessDockerHealthCheckModel{Test: []string{"NONE"}},
+		},
+		{
+			name: "invalid duration",
+			model: HarnessDockerHealthCheckModel{
+				Test:     []string{"CMD", "true"},
+				Interval: types.StringValue("soon"),
+			},
+			wantErr: "parsing health check interval",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.model.healthConfig()
+			if tc.wantErr != "" {
+				require.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -50,6 +50,8 @@ type HarnessK3sResourceModel struct {
 	Resources            *ContainerResources              `tfsdk:"resources"`
 	Hooks                *HarnessHooksModel               `tfsdk:"hooks"`
 	KubeletConfig        types.String                     `tfsdk:"kubelet_config"`
+	KubeconfigPath       types.String                     `tfsdk:"kubeconfig_path"`
+	ServerArgs           []string                         `tfsdk:"server_args"`
 }
 
 type RegistryResourceModel struct {
@@ -74,6 +76,22 @@ type HarnessK3sSandboxResourceModel struct {
 	Keyrings     []string                         `tfsdk:"keyrings"`
 }
 
+// kubeconfigPath returns where the cluster's kubeconfig is written. Unless
+// configured, it is a file unique to the harness in the temporary directory.
+func (m *HarnessK3sResourceModel) kubeconfigPath() types.String {
+	if !m.KubeconfigPath.IsNull() && !m.KubeconfigPath.IsUnknown() {
+		return m.KubeconfigPath
+	}
+
+	return types.StringValue(m.defaultKubeconfigPath())
+}
+
+// defaultKubeconfigPath is the kubeconfig path used when none is configured.
+// Its directory belongs to the harness, and is removed with it.
+func (m *HarnessK3sResourceModel) defaultKubeconfigPath() string {
+	return filepath.Join(os.TempDir(), "imagetest", m.Id.ValueString(), "kubeconfig")
+}
+
 func (r *HarnessK3sResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
 	var data HarnessK3sResourceModel
 	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
@@ -81,6 +99,7 @@ func (r *HarnessK3sResource) Create(ctx context.Context, req resource.CreateRequ
 		return
 	}
 
+	data.KubeconfigPath = data.kubeconfigPath()
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 
 	harness, diags := r.harness(ctx, &data)
@@ -89,7 +108,7 @@ func (r *HarnessK3sResource) Create(ctx context.Context, req resource.CreateRequ
 		return
 	}
 
-	resp.Diagnostics.Append(r.create(ctx, req, harness)...)
+	resp.Diagnostics.Append(r.create(ctx, req, "k3s", harness)...)
 }
 
 func (r *HarnessK3sResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
@@ -99,6 +118,7 @@ func (r *HarnessK3sResource) Update(ctx context.Context, req resource.UpdateRequ
 		return
 	}
 
+	data.KubeconfigPath = data.kubeconfigPath()
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 
 	harness, diags := r.harness(ctx, &data)
@@ -107,7 +127,7 @@ func (r *HarnessK3sResource) Update(ctx context.Context, req resource.UpdateRequ
 		return
 	}
 
-	resp.Diagnostics.Append(r.update(ctx, req, harness)...)
+	resp.Diagnostics.Append(r.update(ctx, req, "k3s", harness)...)
 }
 
 func (r *HarnessK3sResource) harness(ctx context.Context, data *HarnessK3sResourceModel) (harness.Harness, diag.Diagnostics) {
@@ -120,6 +140,7 @@ func (r *HarnessK3sResource) harness(ctx context.Context, data *HarnessK3sResour
 
 	kopts := append([]k3s.Option{
 		k3s.WithName(data.Id.ValueString()),
+		k3s.WithKeychain(r.store.keychain),
 		k3s.WithCniDisabled(data.DisableCni.ValueBool()),
 		k3s.WithTraefikDisabled(data.DisableTraefik.ValueBool()),
 		k3s.WithMetricsServerDisabled(data.DisableMetricsServer.ValueBool()),
@@ -280,6 +301,15 @@ func (r *HarnessK3sResource) harness(ctx context.Context, data *HarnessK3sResour
 		kopts = append(kopts, k3s.WithKubeletConfig(data.KubeletConfig.ValueString()))
 	}
 
+	kopts = append(kopts, k3s.WithKubeconfigPath(data.KubeconfigPath.ValueString()))
+	if data.KubeconfigPath.ValueString() == data.defaultKubeconfigPath() {
+		kopts = append(kopts, k3s.WithKubeconfigCleanup())
+	}
+
+	if len(data.ServerArgs) > 0 {
+		kopts = append(kopts, k3s.WithServerArgs(data.ServerArgs...))
+	}
+
 	harness, err := k3s.New(kopts...)
 	if err != nil {
 		return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("failed to initialize k3s harness", err.Error())}
@@ -307,7 +337,13 @@ func (r *HarnessK3sResource) bundler(data *HarnessK3sResourceModel) (bundler.Bun
 			return nil, fmt.Errorf("invalid reference: %w", err)
 		}
 
+		desc, err := r.store.Descriptor(ref)
+		if err != nil {
+			return nil, err
+		}
+
 		return bundler.NewAppender(ref,
+			bundler.AppenderWithDescriptor(desc),
 			bundler.AppenderWithRemoteOptions(r.store.ropts...),
 		)
 	}
@@ -418,6 +454,16 @@ func (r *HarnessK3sResource) Schema(ctx context.Context, _ resource.SchemaReques
 					Description: "The KubeletConfiguration to be applied to the underlying k3s cluster in YAML format.",
 					Optional:    true,
 				},
+				"kubeconfig_path": schema.StringAttribute{
+					Description: "A path on the host to write the cluster's kubeconfig to once the cluster is running. The kubeconfig points at the cluster's host port, so it can be used from outside the harness. Relative paths are resolved against the working directory. Defaults to a file unique to the harness in the system's temporary directory, which is deleted when the harness is torn down and kept when teardown is skipped. A configured path is never deleted.",
+					Optional:    true,
+					Computed:    true,
+				},
+				"server_args": schema.ListAttribute{
+					Description: "Extra flags to pass to the k3s server, in the --key=value form. For example, --kube-apiserver-arg=feature-gates=InPlacePodVerticalScaling=true.",
+					Optional:    true,
+					ElementType: types.StringType,
+				},
 				"registries": schema.MapNestedAttribute{
 					Description: "A map of registries containing configuration for optional auth, tls, and mirror configuration.",
 					Optional:    true,
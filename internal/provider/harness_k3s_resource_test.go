@@ -2,6 +2,9 @@ package provider
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 	"regexp"
 	"testing"
 
@@ -386,6 +389,78 @@ resource "terraform_data" "registry_down" {
           `,
 			},
 		},
+		"with default kubeconfig path": {
+			// Create testing
+			{
+				ExpectNonEmptyPlan: true,
+				Config: `
+data "imagetest_inventory" "this" {}
+
+resource "imagetest_harness_k3s" "test" {
+  name = "test"
+  inventory = data.imagetest_inventory.this
+}
+
+resource "imagetest_feature" "test" {
+  name = "Simple k3s based test"
+  description = "Test that the default kubeconfig is removed with the harness"
+  harness = imagetest_harness_k3s.test
+  steps = [
+    {
+      name = "Access cluster"
+      cmd = "kubectl get po -A"
+    },
+  ]
+}
+          `,
+				Check: resource.TestCheckResourceAttrWith("imagetest_harness_k3s.test", "kubeconfig_path", func(value string) error {
+					if !filepath.IsAbs(value) {
+						return fmt.Errorf("expected an absolute kubeconfig path, got %q", value)
+					}
+					// The harness is torn down once its feature has run, taking
+					// the default kubeconfig with it
+					if _, err := os.Stat(filepath.Dir(value)); !os.IsNotExist(err) {
+						return fmt.Errorf("expected the default kubeconfig directory to be removed, got %v", err)
+					}
+					return nil
+				}),
+			},
+		},
+		"with kubeconfig path": {
+			// Create testing
+			{
+				ExpectNonEmptyPlan: true,
+				Config: `
+data "imagetest_inventory" "this" {}
+
+resource "imagetest_harness_k3s" "test" {
+  name = "test"
+  inventory = data.imagetest_inventory.this
+  kubeconfig_path = "${path.module}/kubeconfig-test"
+}
+
+resource "imagetest_feature" "test" {
+  name = "Simple k3s based test"
+  description = "Test that the kubeconfig is written to the configured path"
+  harness = imagetest_harness_k3s.test
+  steps = [
+    {
+      name = "Access cluster"
+      cmd = "kubectl get po -A"
+    },
+  ]
+}
+          `,
+				Check: resource.TestCheckResourceAttrWith("imagetest_harness_k3s.test", "kubeconfig_path", func(value string) error {
+					defer os.Remove(value)
+					if filepath.Base(value) != "kubeconfig-test" {
+						return fmt.Errorf("expected the configured kubeconfig path, got %q", value)
+					}
+					_, err := os.Stat(value)
+					return err
+				}),
+			},
+		},
 	}
 
 	for name, tc := range testCases {
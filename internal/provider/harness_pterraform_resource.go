@@ -54,7 +54,7 @@ func (r *HarnessPterraformResource) Create(ctx context.Context, req resource.Cre
 		return
 	}
 
-	resp.Diagnostics.Append(r.create(ctx, req, harness)...)
+	resp.Diagnostics.Append(r.create(ctx, req, "pterraform", harness)...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
@@ -75,7 +75,7 @@ func (r *HarnessPterraformResource) Update(ctx context.Context, req resource.Upd
 		return
 	}
 
-	resp.Diagnostics.Append(r.update(ctx, req, harness)...)
+	resp.Diagnostics.Append(r.update(ctx, req, "pterraform", harness)...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
@@ -2,8 +2,12 @@ package provider
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"slices"
 
+	ilog "github.com/chainguard-dev/terraform-provider-imagetest/internal/log"
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
@@ -29,17 +33,20 @@ type ImageTestProvider struct {
 
 // ImageTestProviderModel describes the provider data model.
 type ImageTestProviderModel struct {
-	Log           *ProviderLoggerModel           `tfsdk:"log"`
-	Harnesses     *ImageTestProviderHarnessModel `tfsdk:"harnesses"`
-	TestExecution *ProviderTestExecutionModel    `tfsdk:"test_execution"`
-	Repo          types.String                   `tfsdk:"repo"`
-	Sandbox       *ProviderSandboxModel          `tfsdk:"sandbox"`
+	Log           *ProviderLoggerModel                 `tfsdk:"log"`
+	Harnesses     *ImageTestProviderHarnessModel       `tfsdk:"harnesses"`
+	TestExecution *ProviderTestExecutionModel          `tfsdk:"test_execution"`
+	Repo          types.String                         `tfsdk:"repo"`
+	Sandbox       *ProviderSandboxModel                `tfsdk:"sandbox"`
+	RegistryAuth  map[string]RegistryResourceAuthModel `tfsdk:"registry_auth"`
 }
 
 type ImageTestProviderHarnessModel struct {
 	K3s     *ProviderHarnessK3sModel     `tfsdk:"k3s"`
 	Docker  *ProviderHarnessDockerModel  `tfsdk:"docker"`
 	Cluster *ProviderHarnessClusterModel `tfsdk:"cluster"`
+	// Serialize lists the harness kinds whose creation is serialized.
+	Serialize []string `tfsdk:"serialize"`
 }
 
 type ProviderHarnessK3sModel struct {
@@ -66,7 +73,8 @@ type ProviderHarnessDockerModel struct {
 }
 
 type ProviderLoggerModel struct {
-	File *ProviderLoggerFileModel `tfsdk:"file"`
+	Level types.String             `tfsdk:"level"`
+	File  *ProviderLoggerFileModel `tfsdk:"file"`
 }
 
 type ProviderLoggerFileModel struct {
@@ -94,6 +102,25 @@ func (p *ImageTestProvider) Schema(ctx context.Context, req provider.SchemaReque
 				Optional:    true,
 				Description: "The target repository the provider will use for pushing/pulling dynamically built images.",
 			},
+			"registry_auth": schema.MapNestedAttribute{
+				Description:         "A map of registries to static credentials. When set, these are the only credentials used for registry auth and ambient credentials are ignored.",
+				MarkdownDescription: "A map of registries to static credentials. When set, these are the only credentials used for registry auth (pushing built images, pulling harness images, and harness registry configuration), and ambient credentials such as `~/.docker/config.json` are ignored. Registries not in the map are accessed anonymously.",
+				Optional:            true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"username": schema.StringAttribute{
+							Optional: true,
+						},
+						"password": schema.StringAttribute{
+							Optional:  true,
+							Sensitive: true,
+						},
+						"auth": schema.StringAttribute{
+							Optional: true,
+						},
+					},
+				},
+			},
 			"test_execution": schema.SingleNestedAttribute{
 				Optional: true,
 				Attributes: map[string]schema.Attribute{
@@ -122,6 +149,10 @@ func (p *ImageTestProvider) Schema(ctx context.Context, req provider.SchemaReque
 			"log": schema.SingleNestedAttribute{
 				Optional: true,
 				Attributes: map[string]schema.Attribute{
+					"level": schema.StringAttribute{
+						Description: "The minimum level of logs to output (debug|info|warn|error), both to terraform and to the log file. Defaults to terraform's own filtering, and info for the log file.",
+						Optional:    true,
+					},
 					"file": schema.SingleNestedAttribute{
 						Description: "Output logs to a file.",
 						Optional:    true,
@@ -300,6 +331,11 @@ func (p *ImageTestProvider) Schema(ctx context.Context, req provider.SchemaReque
 							},
 						},
 					},
+					"serialize": schema.ListAttribute{
+						Description: "The kinds of harnesses (docker, k3s, or pterraform) to create one at a time, for environments that can't create them concurrently, such as a single shared docker daemon. Time spent waiting counts towards the harness create timeout.",
+						Optional:    true,
+						ElementType: types.StringType,
+					},
 				},
 			},
 		},
@@ -355,7 +391,17 @@ func (p *ImageTestProvider) Configure(ctx context.Context, req provider.Configur
 		}
 	}
 
-	store, err := NewProviderStore(repo)
+	var kc authn.Keychain
+	if len(data.RegistryAuth) > 0 {
+		auths, err := newStaticKeychain(data.RegistryAuth)
+		if err != nil {
+			resp.Diagnostics.AddError("invalid registry_auth", err.Error())
+			return
+		}
+		kc = auths
+	}
+
+	store, err := NewProviderStore(repo, kc)
 	if err != nil {
 		resp.Diagnostics.AddError("failed to create provider store", err.Error())
 		return
@@ -372,6 +418,23 @@ func (p *ImageTestProvider) Configure(ctx context.Context, req provider.Configur
 		return
 	}
 
+	if data.Log != nil && !data.Log.Level.IsNull() {
+		if _, err := ilog.ParseLevel(data.Log.Level.ValueString()); err != nil {
+			resp.Diagnostics.AddError("invalid log level", err.Error())
+			return
+		}
+	}
+
+	if data.Harnesses != nil {
+		for _, kind := range data.Harnesses.Serialize {
+			if !slices.Contains(harnessKinds, kind) {
+				resp.Diagnostics.AddError("invalid harness kind", fmt.Sprintf("cannot serialize unknown harness kind %q, must be one of %v", kind, harnessKinds))
+				return
+			}
+		}
+		store.serializeHarnesses(data.Harnesses.Serialize...)
+	}
+
 	// Store any "global" provider configuration in the store
 	store.providerResourceData = data
 
@@ -384,6 +447,7 @@ func (p *ImageTestProvider) Resources(_ context.Context) []func() resource.Resou
 	return []func() resource.Resource{
 		NewFeatureResource,
 		NewContainerVolumeResource,
+		NewCleanupResource,
 		// Harnesses
 		NewHarnessK3sResource,
 		NewHarnessDockerResource,
@@ -19,6 +19,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/google"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	slogmulti "github.com/samber/slog-multi"
+	"golang.org/x/sync/singleflight"
 )
 
 // ProviderStore manages the global runtime state of the provider. The provider
@@ -41,10 +42,27 @@ type ProviderStore struct {
 	providerResourceData ImageTestProviderModel
 	repo                 name.Repository
 	ropts                []remote.Option
+	// keychain is used for all registry auth, both for remote operations and
+	// for images pulled by the docker daemon.
+	keychain authn.Keychain
+	// descriptors caches resolved image descriptors for the lifetime of the
+	// provider process, so base images shared by many resources are only
+	// resolved once.
+	descriptors *mmap[string, *remote.Descriptor]
+	descgroup   singleflight.Group
+	// harnessLocks holds a single slot semaphore for each harness kind whose
+	// creation is serialized. It is only written while configuring the
+	// provider.
+	harnessLocks map[string]chan struct{}
 }
 
-func NewProviderStore(repo name.Repository) (*ProviderStore, error) {
-	kc := authn.NewMultiKeychain(google.Keychain, authn.DefaultKeychain)
+// NewProviderStore creates a new ProviderStore. When kc is nil, the ambient
+// credentials (google and docker config) are used for registry auth.
+func NewProviderStore(repo name.Repository, kc authn.Keychain) (*ProviderStore, error) {
+	if kc == nil {
+		kc = authn.NewMultiKeychain(google.Keychain, authn.DefaultKeychain)
+	}
+
 	ropts := []remote.Option{
 		remote.WithAuthFromKeychain(kc),
 		remote.WithUserAgent("terraform-provider-imagetest"),
@@ -67,11 +85,46 @@ func NewProviderStore(repo name.Repository) (*ProviderStore, error) {
 			store: make(map[string]harness.Harness),
 			mu:    sync.Mutex{},
 		},
-		repo:  repo,
-		ropts: ropts,
+		descriptors: &mmap[string, *remote.Descriptor]{
+			store: make(map[string]*remote.Descriptor),
+			mu:    sync.Mutex{},
+		},
+		harnessLocks: make(map[string]chan struct{}),
+		repo:         repo,
+		ropts:        ropts,
+		keychain:     kc,
 	}, nil
 }
 
+// Descriptor resolves ref, returning the cached descriptor when ref has
+// already been resolved by this provider process. Concurrent resolutions of
+// the same ref are deduplicated.
+func (s *ProviderStore) Descriptor(ref name.Reference) (*remote.Descriptor, error) {
+	key := ref.Name()
+	if desc, ok := s.descriptors.Get(key); ok {
+		return desc, nil
+	}
+
+	v, err, _ := s.descgroup.Do(key, func() (any, error) {
+		if desc, ok := s.descriptors.Get(key); ok {
+			return desc, nil
+		}
+
+		desc, err := remote.Get(ref, s.ropts...)
+		if err != nil {
+			return nil, fmt.Errorf("resolving %s: %w", key, err)
+		}
+		s.descriptors.Set(key, desc)
+
+		return desc, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*remote.Descriptor), nil
+}
+
 func (s *ProviderStore) Encode(components ...string) (string, error) {
 	hasher := sha256.New()
 	for _, component := range components {
@@ -94,8 +147,23 @@ func (s *ProviderStore) Logger(ctx context.Context, inv InventoryDataSourceModel
 	ctx = clog.WithLogger(ctx, logger)
 
 	plog := s.providerResourceData.Log
+	if plog == nil || (plog.File == nil && plog.Level.IsNull()) {
+		return ctx, nil
+	}
+
+	var level slog.Leveler
+	if !plog.Level.IsNull() {
+		l, err := ilog.ParseLevel(plog.Level.ValueString())
+		if err != nil {
+			return ctx, err
+		}
+		level = l
+	}
+
+	handlers := []slog.Handler{&ilog.TFHandler{Level: level}}
+	args := withs
 
-	if plog != nil && plog.File != nil {
+	if plog.File != nil {
 		ihash, err := s.Encode(inv.Seed.ValueString())
 		if err != nil {
 			return ctx, fmt.Errorf("failed to encode inventory hash: %w", err)
@@ -117,20 +185,18 @@ func (s *ProviderStore) Logger(ctx context.Context, inv InventoryDataSourceModel
 		var fhandler slog.Handler
 		switch plog.File.Format.ValueString() {
 		case "text":
-			fhandler = slog.NewTextHandler(f, &slog.HandlerOptions{})
+			fhandler = slog.NewTextHandler(f, &slog.HandlerOptions{Level: level})
 		default:
-			fhandler = slog.NewJSONHandler(f, &slog.HandlerOptions{})
+			fhandler = slog.NewJSONHandler(f, &slog.HandlerOptions{Level: level})
 		}
 
-		logger := clog.New(slogmulti.Fanout(
-			&ilog.TFHandler{},
-			fhandler,
-		)).With("inventory", ihash)
-
-		ctx = clog.WithLogger(ctx, logger)
+		handlers = append(handlers, fhandler)
+		args = append(args, "inventory", ihash)
 	}
 
-	return ctx, nil
+	logger = clog.New(slogmulti.Fanout(handlers...)).With(args...)
+
+	return clog.WithLogger(ctx, logger), nil
 }
 
 // SkipTeardown returns true if harnesses should skip teardown steps.
@@ -138,6 +204,63 @@ func (s *ProviderStore) SkipTeardown() bool {
 	return s.skipTeardown
 }
 
+// staticKeychain resolves credentials from a fixed set of registries, keyed
+// by registry host. Any registry not in the set is resolved anonymously.
+type staticKeychain map[string]authn.AuthConfig
+
+// newStaticKeychain creates a staticKeychain from the provider's registry_auth.
+// Registries are normalized the same way references are, so credentials for
+// docker.io are used for index.docker.io.
+func newStaticKeychain(auths map[string]RegistryResourceAuthModel) (staticKeychain, error) {
+	kc := make(staticKeychain, len(auths))
+	for reg, a := range auths {
+		r, err := name.NewRegistry(reg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid registry %q: %w", reg, err)
+		}
+
+		kc[r.RegistryStr()] = authn.AuthConfig{
+			Username: a.Username.ValueString(),
+			Password: a.Password.ValueString(),
+			Auth:     a.Auth.ValueString(),
+		}
+	}
+	return kc, nil
+}
+
+func (k staticKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	if cfg, ok := k[target.RegistryStr()]; ok {
+		return authn.FromConfig(cfg), nil
+	}
+	return authn.Anonymous, nil
+}
+
+// serializeHarnesses serializes the creation of the given harness kinds, for
+// environments that can't create them concurrently.
+func (s *ProviderStore) serializeHarnesses(kinds ...string) {
+	for _, kind := range kinds {
+		if _, ok := s.harnessLocks[kind]; !ok {
+			s.harnessLocks[kind] = make(chan struct{}, 1)
+		}
+	}
+}
+
+// lockHarness blocks until no other harness of the same kind is being created,
+// if creation of that kind is serialized. The returned func releases the lock.
+func (s *ProviderStore) lockHarness(ctx context.Context, kind string) (func(), error) {
+	sem, ok := s.harnessLocks[kind]
+	if !ok {
+		return func() {}, nil
+	}
+
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting to create %s harness: %w", kind, ctx.Err())
+	}
+}
+
 // mmap is a generic thread-safe map implementation.
 type mmap[K comparable, V any] struct {
 	mu    sync.Mutex
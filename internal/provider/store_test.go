@@ -0,0 +1,191 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderStoreDescriptor(t *testing.T) {
+	var manifests atomic.Int32
+	reg := registry.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/manifests/") {
+			manifests.Add(1)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+	ref, err := name.ParseReference(fmt.Sprintf("%s/base:latest", host))
+	require.NoError(t, err)
+
+	img, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+	manifests.Store(0)
+
+	repo, err := name.NewRepository(fmt.Sprintf("%s/imagetest", host))
+	require.NoError(t, err)
+
+	s, err := NewProviderStore(repo, staticKeychain{})
+	require.NoError(t, err)
+
+	want, err := img.Digest()
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			desc, err := s.Descriptor(ref)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if desc.Digest != want {
+				errs <- fmt.Errorf("got digest %s, want %s", desc.Digest, want)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, int32(1), manifests.Load())
+
+	// Unknown refs are not cached
+	_, err = s.Descriptor(ref.Context().Tag("missing"))
+	require.Error(t, err)
+	_, err = s.Descriptor(ref.Context().Tag("missing"))
+	require.Error(t, err)
+	require.Equal(t, int32(3), manifests.Load())
+}
+
+func TestStaticKeychain(t *testing.T) {
+	kc, err := newStaticKeychain(map[string]RegistryResourceAuthModel{
+		"docker.io": {
+			Username: types.StringValue("hub"),
+			Password: types.StringValue("hub-password"),
+		},
+		"registry.example.com:5000": {
+			Auth: types.StringValue("dXNlcjpwYXNz"),
+		},
+	})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		ref  string
+		want authn.AuthConfig
+	}{
+		{ref: "nginx", want: authn.AuthConfig{Username: "hub", Password: "hub-password"}},
+		{ref: "docker.io/library/nginx", want: authn.AuthConfig{Username: "hub", Password: "hub-password"}},
+		{ref: "index.docker.io/library/nginx", want: authn.AuthConfig{Username: "hub", Password: "hub-password"}},
+		{ref: "registry.example.com:5000/app", want: authn.AuthConfig{Auth: "dXNlcjpwYXNz"}},
+		{ref: "cgr.dev/chainguard/static", want: authn.AuthConfig{}},
+	} {
+		ref, err := name.ParseReference(tt.ref)
+		require.NoError(t, err)
+
+		auth, err := kc.Resolve(ref.Context())
+		require.NoError(t, err)
+
+		cfg, err := auth.Authorization()
+		require.NoError(t, err)
+		require.Equal(t, tt.want, *cfg, tt.ref)
+	}
+
+	_, err = newStaticKeychain(map[string]RegistryResourceAuthModel{"https://example.com": {}})
+	require.Error(t, err)
+}
+
+func TestProviderStoreLockHarness(t *testing.T) {
+	ctx := context.Background()
+
+	repo, err := name.NewRepository("localhost/imagetest")
+	require.NoError(t, err)
+
+	store, err := NewProviderStore(repo, staticKeychain{})
+	require.NoError(t, err)
+	store.serializeHarnesses("docker")
+
+	// create runs n concurrent creates of the given kind, and returns the
+	// maximum number that ran at once
+	create := func(kind string, n int) int32 {
+		var (
+			wg              sync.WaitGroup
+			running, maxRun atomic.Int32
+		)
+		errs := make(chan error, n)
+		for range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				unlock, err := store.lockHarness(ctx, kind)
+				if err != nil {
+					errs <- err
+					return
+				}
+				defer unlock()
+
+				cur := running.Add(1)
+				for {
+					prev := maxRun.Load()
+					if cur <= prev || maxRun.CompareAndSwap(prev, cur) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				running.Add(-1)
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			require.NoError(t, err)
+		}
+		return maxRun.Load()
+	}
+
+	require.Equal(t, int32(1), create("docker", 8))
+
+	// Other kinds are not serialized, so a second create doesn't wait on the
+	// first
+	unlockK3s, err := store.lockHarness(ctx, "k3s")
+	require.NoError(t, err)
+	defer unlockK3s()
+
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	unlock, err := store.lockHarness(tctx, "k3s")
+	require.NoError(t, err)
+	unlock()
+
+	// Waiting for the lock respects the context
+	unlock, err = store.lockHarness(ctx, "docker")
+	require.NoError(t, err)
+	defer unlock()
+
+	_, err = store.lockHarness(tctx, "docker")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -205,7 +205,7 @@ func (r *TestDockerRunResource) do(ctx context.Context, data *TestDockerRunResou
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	cli, err := docker.New()
+	cli, err := docker.New(docker.WithKeychain(r.store.keychain))
 	if err != nil {
 		return []diag.Diagnostic{diag.NewErrorDiagnostic("failed to create docker client", err.Error())}
 	}
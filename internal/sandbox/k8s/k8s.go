@@ -2,15 +2,21 @@ package k8s
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/chainguard-dev/terraform-provider-imagetest/internal/harness"
+	"github.com/chainguard-dev/terraform-provider-imagetest/internal/log"
 	"github.com/chainguard-dev/terraform-provider-imagetest/internal/sandbox"
 	"github.com/google/go-containerregistry/pkg/name"
 	authorizationv1 "k8s.io/api/authorization/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/httpstream"
 	"k8s.io/apimachinery/pkg/watch"
@@ -23,6 +29,10 @@ import (
 
 var _ sandbox.Sandbox = &k8s{}
 
+// usageTimeout bounds how long reporting the sandbox's resource usage may
+// take, since metrics-server is optional and may not respond.
+const usageTimeout = 10 * time.Second
+
 type Request struct {
 	sandbox.Request
 }
@@ -37,6 +47,125 @@ type k8s struct {
 
 	// gracePeriod is the grace period to use when deleting resources
 	gracePeriod int64
+
+	// sidecars are additional containers run alongside the sandbox container
+	// in the same pod.
+	sidecars []corev1.Container
+
+	// annotations are added to the sandbox pod.
+	annotations map[string]string
+
+	// pullPolicy is the image pull policy of the sandbox and sidecar
+	// containers. When unset, the cluster default is used.
+	pullPolicy corev1.PullPolicy
+
+	dnsPolicy corev1.DNSPolicy
+	dnsConfig *corev1.PodDNSConfig
+
+	// nodeName pins the sandbox pod to a specific node, bypassing the
+	// scheduler.
+	nodeName string
+
+	// serviceAccount is a pre-existing service account to run the sandbox
+	// as. When set, no service account or role binding is created.
+	serviceAccount string
+
+	// backoffLimit runs the sandbox as a Job with the given backoff limit
+	// instead of a bare pod, so it is rescheduled if the pod is lost.
+	backoffLimit *int32
+	// job is the Job running the sandbox pod, when running as a Job.
+	job *batchv1.Job
+
+	// usage fetches the resource usage of the sandbox pod's containers. It is
+	// a field so tests can stand in for metrics-server.
+	usage func(context.Context, *corev1.Pod) ([]ContainerUsage, error)
+	// podExec runs a command in a pod. It is a field so tests can stand in
+	// for the exec subresource.
+	podExec func(context.Context, *corev1.Pod, harness.Command) error
+
+	// timings are the run timings of the sandbox pod.
+	timings RunTimings
+}
+
+// ContainerUsage is the resource usage of a sandbox pod container, as last
+// sampled by metrics-server. It is a point in time sample, not a peak.
+type ContainerUsage struct {
+	Name   string
+	CPU    resource.Quantity
+	Memory resource.Quantity
+}
+
+func (u ContainerUsage) String() string {
+	return fmt.Sprintf("%s: cpu=%s memory=%s", u.Name, u.CPU.String(), u.Memory.String())
+}
+
+// RunTimings records when the sandbox pod progressed through its lifecycle.
+// This is used to quantify the cold start overhead of the cluster.
+type RunTimings struct {
+	Created   time.Time
+	Scheduled time.Time
+	Started   time.Time
+	// Terminated is when the sandbox container exited, or when the sandbox was
+	// destroyed while it was still running.
+	Terminated time.Time
+}
+
+// SchedulingLatency is the time between the pod being created and scheduled.
+func (t RunTimings) SchedulingLatency() time.Duration {
+	return between(t.Created, t.Scheduled)
+}
+
+// StartupLatency is the time between the pod being scheduled and the sandbox
+// container starting, which is dominated by image pulls.
+func (t RunTimings) StartupLatency() time.Duration {
+	return between(t.Scheduled, t.Started)
+}
+
+// StartTotal is the time between the pod being created and the sandbox
+// container starting.
+func (t RunTimings) StartTotal() time.Duration {
+	return between(t.Created, t.Started)
+}
+
+// RunDuration is the time between the sandbox container starting and
+// terminating.
+func (t RunTimings) RunDuration() time.Duration {
+	return between(t.Started, t.Terminated)
+}
+
+func between(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// podTimings computes the run timings from the pod's status.
+func podTimings(pod *corev1.Pod, container string) RunTimings {
+	t := RunTimings{
+		Created: pod.CreationTimestamp.Time,
+	}
+
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodScheduled && cond.Status == corev1.ConditionTrue {
+			t.Scheduled = cond.LastTransitionTime.Time
+		}
+	}
+
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name != container {
+			continue
+		}
+		switch {
+		case cs.State.Running != nil:
+			t.Started = cs.State.Running.StartedAt.Time
+		case cs.State.Terminated != nil:
+			t.Started = cs.State.Terminated.StartedAt.Time
+			t.Terminated = cs.State.Terminated.FinishedAt.Time
+		}
+	}
+
+	return t
 }
 
 func NewFromConfig(config *rest.Config, opts ...Option) (*k8s, error) {
@@ -59,6 +188,8 @@ func NewFromConfig(config *rest.Config, opts ...Option) (*k8s, error) {
 		cli:   cli,
 		stack: harness.NewStack(),
 	}
+	k.usage = k.podUsage
+	k.podExec = k.execInPod
 
 	for _, opt := range opts {
 		if err := opt(k); err != nil {
@@ -86,46 +217,232 @@ func (k *k8s) Start(ctx context.Context) (sandbox.Runner, error) {
 	}
 	k.pod = pod
 
+	k.timings = podTimings(pod, pod.Spec.Containers[0].Name)
+	log.Info(ctx, "sandbox pod started",
+		"pod", pod.Name,
+		"scheduling_latency", k.timings.SchedulingLatency().String(),
+		"startup_latency", k.timings.StartupLatency().String(),
+		"total", k.timings.StartTotal().String(),
+	)
+
 	return &response{
-		cmd: func(ctx context.Context, cmd harness.Command) error {
-			req := k.cli.CoreV1().RESTClient().Post().Resource("pods").
-				Name(pod.Name).
-				Namespace(pod.Namespace).
-				SubResource("exec").
-				VersionedParams(&corev1.PodExecOptions{
-					Container: pod.Spec.Containers[0].Name,
-					Command:   []string{"sh", "-c", cmd.Args},
-					Stdout:    true,
-					Stderr:    true,
-				}, scheme.ParameterCodec)
-
-			spdyexec, err := remotecommand.NewSPDYExecutor(k.cfg, "POST", req.URL())
-			if err != nil {
-				return fmt.Errorf("creating exec request: %w", err)
-			}
-			wsexec, err := remotecommand.NewWebSocketExecutor(k.cfg, "GET", req.URL().String())
-			if err != nil {
-				return fmt.Errorf("creating exec request: %w", err)
-			}
+		cmd: k.exec,
+	}, nil
+}
 
-			exec, err := remotecommand.NewFallbackExecutor(wsexec, spdyexec, httpstream.IsUpgradeFailure)
-			if err != nil {
-				return fmt.Errorf("creating exec request: %w", err)
+// exec runs cmd in the sandbox pod. When running as a Job and the pod is lost,
+// such as when its node is preempted, the command is rerun in the Job's
+// replacement pod until the Job itself fails.
+func (k *k8s) exec(ctx context.Context, cmd harness.Command) error {
+	for {
+		pod := k.pod
+
+		err := k.podExec(ctx, pod, cmd)
+		if err == nil {
+			return nil
+		}
+
+		if k.job != nil {
+			if lost, lerr := k.podLost(ctx, pod); lerr == nil && lost {
+				log.Warn(ctx, "sandbox pod was lost, waiting for the job to replace it", "pod", pod.Name, "error", err)
+
+				replacement, rerr := k.replacementPod(ctx, pod)
+				if rerr != nil {
+					return fmt.Errorf("%w (sandbox pod %s was lost: %v)", err, pod.Name, rerr)
+				}
+				k.pod = replacement
+				k.timings = podTimings(replacement, replacement.Spec.Containers[0].Name)
+				continue
 			}
+		}
 
-			return exec.StreamWithContext(ctx, remotecommand.StreamOptions{
-				Stdout: cmd.Stdout,
-				Stderr: cmd.Stderr,
-			})
-		},
-	}, nil
+		// Include the sandbox's resource usage to help diagnose steps that
+		// fail from resource exhaustion
+		mctx, cancel := context.WithTimeout(ctx, usageTimeout)
+		usage, uerr := k.usage(mctx, pod)
+		cancel()
+		if uerr == nil && len(usage) > 0 {
+			return fmt.Errorf("%w (sandbox usage: %s)", err, formatUsage(usage))
+		}
+		return err
+	}
+}
+
+// execInPod runs cmd in the sandbox container of pod.
+func (k *k8s) execInPod(ctx context.Context, pod *corev1.Pod, cmd harness.Command) error {
+	req := k.cli.CoreV1().RESTClient().Post().Resource("pods").
+		Name(pod.Name).
+		Namespace(pod.Namespace).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: pod.Spec.Containers[0].Name,
+			Command:   []string{"sh", "-c", cmd.Args},
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	spdyexec, err := remotecommand.NewSPDYExecutor(k.cfg, "POST", req.URL())
+	if err != nil {
+		return fmt.Errorf("creating exec request: %w", err)
+	}
+	wsexec, err := remotecommand.NewWebSocketExecutor(k.cfg, "GET", req.URL().String())
+	if err != nil {
+		return fmt.Errorf("creating exec request: %w", err)
+	}
+
+	exec, err := remotecommand.NewFallbackExecutor(wsexec, spdyexec, httpstream.IsUpgradeFailure)
+	if err != nil {
+		return fmt.Errorf("creating exec request: %w", err)
+	}
+
+	return exec.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdout: cmd.Stdout,
+		Stderr: cmd.Stderr,
+	})
+}
+
+// podLost reports whether the pod is gone or no longer running.
+func (k *k8s) podLost(ctx context.Context, pod *corev1.Pod) (bool, error) {
+	current, err := k.cli.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return current.DeletionTimestamp != nil || current.Status.Phase != corev1.PodRunning, nil
+}
+
+// replacementPod waits for the Job to replace the lost pod with a running one.
+// It returns an error once the Job has failed, such as when it has exhausted
+// its backoff limit.
+func (k *k8s) replacementPod(ctx context.Context, lost *corev1.Pod) (*corev1.Pod, error) {
+	if err := k.jobFailed(ctx); err != nil {
+		return nil, err
+	}
+
+	return k.waitForPod(ctx, k.job.Namespace, metav1.ListOptions{
+		Watch:         true,
+		LabelSelector: "job-name=" + k.job.Name,
+	}, lost.Name)
+}
+
+// jobFailed returns an error if the sandbox Job has failed.
+func (k *k8s) jobFailed(ctx context.Context) error {
+	job, err := k.cli.BatchV1().Jobs(k.job.Namespace).Get(ctx, k.job.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("getting job: %w", err)
+	}
+
+	for _, cond := range job.Status.Conditions {
+		if cond.Type == batchv1.JobFailed && cond.Status == corev1.ConditionTrue {
+			return fmt.Errorf("job %s failed: %s: %s", job.Name, cond.Reason, cond.Message)
+		}
+	}
+
+	return nil
+}
+
+// Timings returns the run timings of the sandbox pod. They are complete once
+// the sandbox is destroyed, and zero if it never started. When running as a
+// Job, they are the timings of the last pod to run the sandbox.
+func (k *k8s) Timings() RunTimings {
+	return k.timings
 }
 
 // Destroy implements sandbox.Sandbox.
 func (k *k8s) Destroy(ctx context.Context) error {
+	if k.pod != nil {
+		// Don't let a slow api server or metrics-server hold up the teardown
+		mctx, cancel := context.WithTimeout(ctx, usageTimeout)
+		k.timings = k.finalTimings(mctx)
+		usage, err := k.usage(mctx, k.pod)
+		cancel()
+
+		log.Info(ctx, "sandbox pod finished",
+			"pod", k.pod.Name,
+			"run_duration", k.timings.RunDuration().String(),
+		)
+		if err != nil {
+			// metrics-server is optional, so this is not an error
+			log.Debug(ctx, "sandbox resource usage is unavailable", "error", err)
+		} else {
+			for _, u := range usage {
+				log.Info(ctx, "sandbox container resource usage",
+					"container", u.Name,
+					"cpu", u.CPU.String(),
+					"memory", u.Memory.String(),
+				)
+			}
+		}
+	}
+
 	return k.stack.Teardown(ctx)
 }
 
+// finalTimings refreshes the sandbox pod's run timings. A sandbox container
+// that is still running is terminated by the teardown, so it is considered
+// terminated now.
+func (k *k8s) finalTimings(ctx context.Context) RunTimings {
+	pod := k.pod
+	if p, err := k.cli.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{}); err == nil {
+		pod = p
+	} else {
+		log.Debug(ctx, "failed to refresh the sandbox pod status", "error", err)
+	}
+
+	t := k.timings
+	if len(pod.Spec.Containers) > 0 {
+		t = podTimings(pod, pod.Spec.Containers[0].Name)
+	}
+	if t.Terminated.IsZero() {
+		t.Terminated = time.Now()
+	}
+	return t
+}
+
+// podMetrics is the subset of the metrics.k8s.io PodMetrics used for
+// reporting usage.
+type podMetrics struct {
+	Containers []struct {
+		Name  string              `json:"name"`
+		Usage corev1.ResourceList `json:"usage"`
+	} `json:"containers"`
+}
+
+// podUsage queries metrics-server for the pod's container usage.
+func (k *k8s) podUsage(ctx context.Context, pod *corev1.Pod) ([]ContainerUsage, error) {
+	data, err := k.cli.CoreV1().RESTClient().Get().
+		AbsPath("/apis/metrics.k8s.io/v1beta1", "namespaces", pod.Namespace, "pods", pod.Name).
+		DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting pod metrics: %w", err)
+	}
+
+	var pm podMetrics
+	if err := json.Unmarshal(data, &pm); err != nil {
+		return nil, fmt.Errorf("decoding pod metrics: %w", err)
+	}
+
+	usage := make([]ContainerUsage, 0, len(pm.Containers))
+	for _, c := range pm.Containers {
+		usage = append(usage, ContainerUsage{
+			Name:   c.Name,
+			CPU:    c.Usage[corev1.ResourceCPU],
+			Memory: c.Usage[corev1.ResourceMemory],
+		})
+	}
+	return usage, nil
+}
+
+func formatUsage(usage []ContainerUsage) string {
+	parts := make([]string, 0, len(usage))
+	for _, u := range usage {
+		parts = append(parts, u.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
 type response struct {
 	cmd func(context.Context, harness.Command) error
 }
@@ -174,8 +491,18 @@ func (k *k8s) setupPod(ctx context.Context) (*corev1.Pod, error) {
 			return nil, fmt.Errorf("getting imagetest namespace: %w", err)
 		}
 
-		// Add it to our teardown stack if we've created it
+		// Add it to our teardown stack if we've created it. Other runs may
+		// have since started using the namespace, so leave it in place
+		// unless it is empty.
 		if err := k.stack.Add(func(ctx context.Context) error {
+			inuse, err := k.namespaceInUse(ctx, ns.Name)
+			if err != nil {
+				return err
+			}
+			if inuse {
+				log.Info(ctx, "leaving imagetest namespace in place since it is still in use", "namespace", ns.Name)
+				return nil
+			}
 			return k.cli.CoreV1().Namespaces().Delete(ctx, ns.Name, metav1.DeleteOptions{
 				GracePeriodSeconds: &k.gracePeriod,
 			})
@@ -198,30 +525,183 @@ func (k *k8s) setupPod(ctx context.Context) (*corev1.Pod, error) {
 		k.request.Name = dryns.Name
 	}
 
+	sa, err := k.setupServiceAccount(ctx, ns.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	preq := k.podRequest(ns.Name, sa)
+
+	lopts := metav1.ListOptions{Watch: true}
+	if k.backoffLimit != nil {
+		job, err := k.createJob(ctx, preq)
+		if err != nil {
+			return nil, err
+		}
+		k.job = job
+		lopts.LabelSelector = "job-name=" + job.Name
+	} else {
+		// Now create the stupidly privileged pod that we'll use to run the steps
+		pod, err := k.cli.CoreV1().Pods(ns.Name).Create(ctx, preq, metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("creating pod: %w", err)
+		}
+
+		if err := k.stack.Add(func(ctx context.Context) error {
+			return k.cli.CoreV1().Pods(ns.Name).Delete(ctx, pod.Name, metav1.DeleteOptions{
+				GracePeriodSeconds: &k.gracePeriod,
+			})
+		}); err != nil {
+			return nil, fmt.Errorf("adding pod teardown to stack: %w", err)
+		}
+		lopts.FieldSelector = "metadata.name=" + pod.Name
+	}
+
+	// Block until the pod is running
+	return k.waitForPod(ctx, ns.Name, lopts, "")
+}
+
+// waitForPod watches the pods matching lopts until one other than skip is
+// running. In Job mode, deleted and failed pods are expected to be replaced,
+// so it keeps waiting until the Job itself fails.
+func (k *k8s) waitForPod(ctx context.Context, namespace string, lopts metav1.ListOptions, skip string) (*corev1.Pod, error) {
+	watcher, err := k.cli.CoreV1().Pods(namespace).Watch(ctx, lopts)
+	if err != nil {
+		return nil, fmt.Errorf("watching pods: %w", err)
+	}
+	defer watcher.Stop()
+
+	ch := watcher.ResultChan()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case event, ok := <-ch:
+			if !ok {
+				return nil, fmt.Errorf("channel closed")
+			}
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				pod, ok := event.Object.(*corev1.Pod)
+				if !ok {
+					return nil, fmt.Errorf("failed to cast event object to pod")
+				}
+				if pod.Name == skip || pod.DeletionTimestamp != nil {
+					continue
+				}
+				if pod.Status.Phase == corev1.PodRunning {
+					return pod, nil
+				}
+				if pod.Status.Phase == corev1.PodFailed && k.job != nil {
+					if err := k.jobFailed(ctx); err != nil {
+						return nil, err
+					}
+				}
+			case watch.Deleted:
+				// A job replaces its deleted pods, so keep waiting
+				if k.job != nil {
+					continue
+				}
+				return nil, fmt.Errorf("pod was deleted")
+			case watch.Error:
+				return nil, fmt.Errorf("watch error: %v", event.Object)
+			}
+		}
+	}
+}
+
+// createJob creates a Job running the sandbox pod, which is retried up to the
+// backoff limit if the pod fails, such as when its node is preempted.
+func (k *k8s) createJob(ctx context.Context, preq *corev1.Pod) (*batchv1.Job, error) {
+	spec := *preq.Spec.DeepCopy()
+	spec.RestartPolicy = corev1.RestartPolicyNever
+
+	job, err := k.cli.BatchV1().Jobs(preq.Namespace).Create(ctx, &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        preq.Name,
+			Namespace:   preq.Namespace,
+			Labels:      preq.Labels,
+			Annotations: preq.Annotations,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: k.backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      preq.Labels,
+					Annotations: preq.Annotations,
+				},
+				Spec: spec,
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("creating job: %w", err)
+	}
+
+	if err := k.stack.Add(func(ctx context.Context) error {
+		propagation := metav1.DeletePropagationBackground
+		return k.cli.BatchV1().Jobs(job.Namespace).Delete(ctx, job.Name, metav1.DeleteOptions{
+			GracePeriodSeconds: &k.gracePeriod,
+			PropagationPolicy:  &propagation,
+		})
+	}); err != nil {
+		return nil, fmt.Errorf("adding job teardown to stack: %w", err)
+	}
+
+	return job, nil
+}
+
+// namespaceInUse reports whether the namespace has pods that aren't already
+// terminating.
+func (k *k8s) namespaceInUse(ctx context.Context, namespace string) (bool, error) {
+	pods, err := k.cli.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return false, fmt.Errorf("listing pods in namespace %s: %w", namespace, err)
+	}
+
+	for _, pod := range pods.Items {
+		if pod.DeletionTimestamp == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// setupServiceAccount returns the name of the service account the sandbox
+// pod runs as. Unless a pre-existing service account was provided, one is
+// created and bound to cluster-admin.
+func (k *k8s) setupServiceAccount(ctx context.Context, namespace string) (string, error) {
+	if k.serviceAccount != "" {
+		if _, err := k.cli.CoreV1().ServiceAccounts(namespace).Get(ctx, k.serviceAccount, metav1.GetOptions{}); err != nil {
+			return "", fmt.Errorf("getting service account %s: %w", k.serviceAccount, err)
+		}
+		return k.serviceAccount, nil
+	}
+
 	// Create the laundry list of namespace scoped RBAC related resources
-	sa, err := k.cli.CoreV1().ServiceAccounts(ns.Name).Create(ctx, &corev1.ServiceAccount{
+	sa, err := k.cli.CoreV1().ServiceAccounts(namespace).Create(ctx, &corev1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      k.request.Name,
-			Namespace: ns.Name,
+			Namespace: namespace,
 		},
 	}, metav1.CreateOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("creating service account: %w", err)
+		return "", fmt.Errorf("creating service account: %w", err)
 	}
 
 	if err := k.stack.Add(func(ctx context.Context) error {
-		return k.cli.CoreV1().ServiceAccounts(ns.Name).Delete(ctx, sa.Name, metav1.DeleteOptions{
+		return k.cli.CoreV1().ServiceAccounts(namespace).Delete(ctx, sa.Name, metav1.DeleteOptions{
 			GracePeriodSeconds: &k.gracePeriod,
 		})
 	}); err != nil {
-		return nil, fmt.Errorf("adding service account teardown to stack: %w", err)
+		return "", fmt.Errorf("adding service account teardown to stack: %w", err)
 	}
 
 	// Finally, create the role binding
+	// Cluster role bindings are cluster scoped, so they have no namespace
 	rb, err := k.cli.RbacV1().ClusterRoleBindings().Create(ctx, &rbacv1.ClusterRoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      k.request.Name,
-			Namespace: ns.Name,
+			Name: k.request.Name,
 		},
 		Subjects: []rbacv1.Subject{
 			{
@@ -237,7 +717,7 @@ func (k *k8s) setupPod(ctx context.Context) (*corev1.Pod, error) {
 		},
 	}, metav1.CreateOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("creating role binding: %w", err)
+		return "", fmt.Errorf("creating role binding: %w", err)
 	}
 
 	if err := k.stack.Add(func(ctx context.Context) error {
@@ -245,16 +725,25 @@ func (k *k8s) setupPod(ctx context.Context) (*corev1.Pod, error) {
 			GracePeriodSeconds: &k.gracePeriod,
 		})
 	}); err != nil {
-		return nil, fmt.Errorf("adding role binding teardown to stack: %w", err)
+		return "", fmt.Errorf("adding role binding teardown to stack: %w", err)
 	}
 
+	return sa.Name, nil
+}
+
+// podRequest builds the sandbox pod spec for the given namespace and service
+// account.
+func (k *k8s) podRequest(namespace, serviceAccount string) *corev1.Pod {
 	preq := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      k.request.Name,
-			Namespace: ns.Name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"dev.chainguard.imagetest": "true",
+			},
 		},
 		Spec: corev1.PodSpec{
-			ServiceAccountName: sa.Name,
+			ServiceAccountName: serviceAccount,
 			SecurityContext: &corev1.PodSecurityContext{
 				RunAsUser:  &k.request.User,
 				RunAsGroup: &k.request.Group,
@@ -360,53 +849,34 @@ func (k *k8s) setupPod(ctx context.Context) (*corev1.Pod, error) {
 		preq.Labels[k] = v
 	}
 
-	// Now create the stupidly privileged pod that we'll use to run the steps
-	pod, err := k.cli.CoreV1().Pods(ns.Name).Create(ctx, preq, metav1.CreateOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("creating pod: %w", err)
+	if len(k.annotations) > 0 {
+		preq.Annotations = make(map[string]string, len(k.annotations))
+		for k, v := range k.annotations {
+			preq.Annotations[k] = v
+		}
 	}
 
-	if err := k.stack.Add(func(ctx context.Context) error {
-		return k.cli.CoreV1().Pods(ns.Name).Delete(ctx, pod.Name, metav1.DeleteOptions{
-			GracePeriodSeconds: &k.gracePeriod,
-		})
-	}); err != nil {
-		return nil, fmt.Errorf("adding pod teardown to stack: %w", err)
+	if k.dnsPolicy != "" {
+		preq.Spec.DNSPolicy = k.dnsPolicy
 	}
 
-	// Block until the pod is running
-	watcher, err := k.cli.CoreV1().Pods(ns.Name).Watch(ctx, metav1.ListOptions{
-		Watch:         true,
-		FieldSelector: "metadata.name=" + pod.Name,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("creating pod: %w", err)
+	if k.dnsConfig != nil {
+		preq.Spec.DNSConfig = k.dnsConfig
 	}
-	defer watcher.Stop()
 
-	ch := watcher.ResultChan()
-	for {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case event, ok := <-ch:
-			if !ok {
-				return nil, fmt.Errorf("channel closed")
-			}
-			switch event.Type {
-			case watch.Added, watch.Modified:
-				pod, ok := event.Object.(*corev1.Pod)
-				if !ok {
-					return nil, fmt.Errorf("failed to cast event object to pod")
-				}
-				if pod.Status.Phase == corev1.PodRunning {
-					return pod, nil
-				}
-			case watch.Deleted:
-				return nil, fmt.Errorf("pod was deleted")
-			case watch.Error:
-				return nil, fmt.Errorf("watch error: %v", event.Object)
-			}
+	if k.nodeName != "" {
+		preq.Spec.NodeName = k.nodeName
+	}
+
+	// Sidecars are appended after the sandbox so the sandbox is always the
+	// first container, which is the one steps are executed in.
+	preq.Spec.Containers = append(preq.Spec.Containers, k.sidecars...)
+
+	if k.pullPolicy != "" {
+		for i := range preq.Spec.Containers {
+			preq.Spec.Containers[i].ImagePullPolicy = k.pullPolicy
 		}
 	}
+
+	return preq
 }
@@ -0,0 +1,671 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chainguard-dev/terraform-provider-imagetest/internal/harness"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestWorkingDir(t *testing.T) {
+	cfg := &rest.Config{Host: "https://127.0.0.1:6443"}
+
+	k, err := NewFromConfig(cfg)
+	require.NoError(t, err)
+
+	pod := k.podRequest("default", "sandbox")
+	require.Empty(t, pod.Spec.Containers[0].WorkingDir)
+
+	k, err = NewFromConfig(cfg, WithWorkingDir("/imagetest/work"))
+	require.NoError(t, err)
+
+	pod = k.podRequest("default", "sandbox")
+	require.Equal(t, "/imagetest/work", pod.Spec.Containers[0].WorkingDir)
+	require.Equal(t, "default", pod.Namespace)
+	require.Equal(t, "sandbox", pod.Spec.ServiceAccountName)
+
+	// An empty working directory keeps the default
+	k, err = NewFromConfig(cfg, WithWorkingDir(""))
+	require.NoError(t, err)
+	require.Empty(t, k.podRequest("default", "sandbox").Spec.Containers[0].WorkingDir)
+}
+
+func TestSidecar(t *testing.T) {
+	cfg := &rest.Config{Host: "https://127.0.0.1:6443"}
+
+	k, err := NewFromConfig(cfg,
+		WithSidecar("postgres", "cgr.dev/chainguard/postgres:latest", []int{5432}, map[string]string{
+			"POSTGRES_PASSWORD": "password",
+			"PGDATA":            "/var/lib/postgresql/data",
+		}),
+	)
+	require.NoError(t, err)
+
+	pod := k.podRequest("default", "sandbox")
+	require.Len(t, pod.Spec.Containers, 2)
+
+	// The sandbox must always be the first container
+	require.Equal(t, "sandbox", pod.Spec.Containers[0].Name)
+
+	sc := pod.Spec.Containers[1]
+	require.Equal(t, "postgres", sc.Name)
+	require.Equal(t, "cgr.dev/chainguard/postgres:latest", sc.Image)
+	require.Len(t, sc.Ports, 1)
+	require.Equal(t, int32(5432), sc.Ports[0].ContainerPort)
+	require.Equal(t, []corev1.EnvVar{
+		{Name: "PGDATA", Value: "/var/lib/postgresql/data"},
+		{Name: "POSTGRES_PASSWORD", Value: "password"},
+	}, sc.Env)
+
+	_, err = NewFromConfig(cfg, WithSidecar("sandbox", "foo", nil, nil))
+	require.Error(t, err)
+}
+
+func TestDNSConfig(t *testing.T) {
+	cfg := &rest.Config{Host: "https://127.0.0.1:6443"}
+
+	k, err := NewFromConfig(cfg)
+	require.NoError(t, err)
+
+	pod := k.podRequest("default", "sandbox")
+	require.Empty(t, pod.Spec.DNSPolicy)
+	require.Nil(t, pod.Spec.DNSConfig)
+
+	ndots := "1"
+	dnscfg := &corev1.PodDNSConfig{
+		Nameservers: []string{"10.0.0.10"},
+		Options: []corev1.PodDNSConfigOption{
+			{Name: "ndots", Value: &ndots},
+		},
+	}
+
+	k, err = NewFromConfig(cfg,
+		WithDNSPolicy(corev1.DNSNone),
+		WithDNSConfig(dnscfg),
+	)
+	require.NoError(t, err)
+
+	pod = k.podRequest("default", "sandbox")
+	require.Equal(t, corev1.DNSNone, pod.Spec.DNSPolicy)
+	require.Equal(t, dnscfg, pod.Spec.DNSConfig)
+}
+
+func TestNodeName(t *testing.T) {
+	cfg := &rest.Config{Host: "https://127.0.0.1:6443"}
+
+	k, err := NewFromConfig(cfg)
+	require.NoError(t, err)
+	require.Empty(t, k.podRequest("default", "sandbox").Spec.NodeName)
+
+	k, err = NewFromConfig(cfg, WithNodeName("node-1"))
+	require.NoError(t, err)
+	require.Equal(t, "node-1", k.podRequest("default", "sandbox").Spec.NodeName)
+}
+
+func TestPodTimings(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			Conditions: []corev1.PodCondition{
+				{
+					Type:               corev1.PodScheduled,
+					Status:             corev1.ConditionTrue,
+					LastTransitionTime: metav1.NewTime(created.Add(2 * time.Second)),
+				},
+				{
+					Type:               corev1.PodReady,
+					Status:             corev1.ConditionTrue,
+					LastTransitionTime: metav1.NewTime(created.Add(30 * time.Second)),
+				},
+			},
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name: "postgres",
+					State: corev1.ContainerState{
+						Running: &corev1.ContainerStateRunning{StartedAt: metav1.NewTime(created.Add(5 * time.Second))},
+					},
+				},
+				{
+					Name: "sandbox",
+					State: corev1.ContainerState{
+						Running: &corev1.ContainerStateRunning{StartedAt: metav1.NewTime(created.Add(12 * time.Second))},
+					},
+				},
+			},
+		},
+	}
+
+	timings := podTimings(pod, "sandbox")
+	require.Equal(t, 2*time.Second, timings.SchedulingLatency())
+	require.Equal(t, 10*time.Second, timings.StartupLatency())
+	require.Equal(t, 12*time.Second, timings.StartTotal())
+	require.Zero(t, timings.RunDuration())
+
+	// A terminated container still reports when it started
+	pod.Status.ContainerStatuses[1].State = corev1.ContainerState{
+		Terminated: &corev1.ContainerStateTerminated{
+			StartedAt:  metav1.NewTime(created.Add(12 * time.Second)),
+			FinishedAt: metav1.NewTime(created.Add(72 * time.Second)),
+		},
+	}
+	timings = podTimings(pod, "sandbox")
+	require.Equal(t, 12*time.Second, timings.StartTotal())
+	require.Equal(t, time.Minute, timings.RunDuration())
+
+	// A pod that hasn't been scheduled has no latencies
+	pod.Status = corev1.PodStatus{}
+	timings = podTimings(pod, "sandbox")
+	require.Zero(t, timings.SchedulingLatency())
+	require.Zero(t, timings.StartupLatency())
+	require.Zero(t, timings.StartTotal())
+	require.Zero(t, timings.RunDuration())
+}
+
+func TestTimings(t *testing.T) {
+	ctx := context.Background()
+	cfg := &rest.Config{Host: "https://127.0.0.1:6443"}
+	created := time.Now().Add(-time.Minute)
+
+	sandboxPod := func(state corev1.ContainerState) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "sandbox",
+				Namespace:         "default",
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "sandbox"}}},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{Name: "sandbox", State: state}},
+			},
+		}
+	}
+	running := sandboxPod(corev1.ContainerState{
+		Running: &corev1.ContainerStateRunning{StartedAt: metav1.NewTime(created.Add(10 * time.Second))},
+	})
+
+	for _, tc := range []struct {
+		name string
+		// current is the pod status when the sandbox is destroyed
+		current      *corev1.Pod
+		wantDuration time.Duration
+	}{
+		{
+			name: "terminated",
+			current: sandboxPod(corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{
+					StartedAt:  metav1.NewTime(created.Add(10 * time.Second)),
+					FinishedAt: metav1.NewTime(created.Add(40 * time.Second)),
+				},
+			}),
+			wantDuration: 30 * time.Second,
+		},
+		{
+			// The container is terminated by the teardown itself
+			name:    "running",
+			current: running,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			k, err := NewFromConfig(cfg)
+			require.NoError(t, err)
+			k.cli = fake.NewClientset(tc.current)
+			k.usage = func(context.Context, *corev1.Pod) ([]ContainerUsage, error) {
+				return nil, fmt.Errorf("no metrics")
+			}
+			k.pod = running
+			k.timings = podTimings(running, "sandbox")
+			require.Equal(t, 10*time.Second, k.Timings().StartTotal())
+			require.True(t, k.Timings().Terminated.IsZero())
+
+			before := time.Now()
+			require.NoError(t, k.Destroy(ctx))
+
+			timings := k.Timings()
+			require.Equal(t, 10*time.Second, timings.StartTotal())
+			if tc.wantDuration != 0 {
+				require.Equal(t, tc.wantDuration, timings.RunDuration())
+				return
+			}
+			require.False(t, timings.Terminated.Before(before))
+			require.GreaterOrEqual(t, timings.RunDuration(), 50*time.Second)
+		})
+	}
+}
+
+// newFakeClientset returns a fake clientset that allows the sandbox to create
+// pods and reports them as running as soon as they are created.
+func newFakeClientset(t *testing.T, objs ...runtime.Object) *fake.Clientset {
+	t.Helper()
+
+	cli := fake.NewClientset(objs...)
+	cli.PrependReactor("create", "selfsubjectaccessreviews", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &authorizationv1.SelfSubjectAccessReview{
+			Status: authorizationv1.SubjectAccessReviewStatus{Allowed: true},
+		}, nil
+	})
+
+	w := watch.NewFakeWithChanSize(1, false)
+	cli.PrependWatchReactor("pods", k8stesting.DefaultWatchReactor(w, nil))
+	cli.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		pod := action.(k8stesting.CreateAction).GetObject().(*corev1.Pod).DeepCopy()
+		pod.Status.Phase = corev1.PodRunning
+		w.Modify(pod)
+		return false, nil, nil
+	})
+
+	return cli
+}
+
+func TestServiceAccount(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := &rest.Config{Host: "https://127.0.0.1:6443"}
+
+	t.Run("provided", func(t *testing.T) {
+		cli := newFakeClientset(t, &corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "workload-identity",
+				Namespace: "default",
+			},
+		})
+
+		k, err := NewFromConfig(cfg, WithServiceAccount("workload-identity"))
+		require.NoError(t, err)
+		k.cli = cli
+		k.request.Name = "imagetest-sa"
+
+		pod, err := k.setupPod(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "workload-identity", pod.Spec.ServiceAccountName)
+
+		// No service account or role binding is created
+		sas, err := cli.CoreV1().ServiceAccounts("default").List(ctx, metav1.ListOptions{})
+		require.NoError(t, err)
+		require.Len(t, sas.Items, 1)
+
+		crbs, err := cli.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+		require.NoError(t, err)
+		require.Empty(t, crbs.Items)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		k, err := NewFromConfig(cfg, WithServiceAccount("workload-identity"))
+		require.NoError(t, err)
+		k.cli = newFakeClientset(t)
+		k.request.Name = "imagetest-sa"
+
+		_, err = k.setupPod(ctx)
+		require.ErrorContains(t, err, "workload-identity")
+	})
+
+	t.Run("default", func(t *testing.T) {
+		cli := newFakeClientset(t)
+
+		k, err := NewFromConfig(cfg)
+		require.NoError(t, err)
+		k.cli = cli
+		k.request.Name = "imagetest-sa"
+
+		pod, err := k.setupPod(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "imagetest-sa", pod.Spec.ServiceAccountName)
+
+		crb, err := cli.RbacV1().ClusterRoleBindings().Get(ctx, "imagetest-sa", metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, "cluster-admin", crb.RoleRef.Name)
+	})
+}
+
+func TestJob(t *testing.T) {
+	ctx := context.Background()
+
+	cli := fake.NewClientset()
+	cli.PrependReactor("create", "selfsubjectaccessreviews", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &authorizationv1.SelfSubjectAccessReview{
+			Status: authorizationv1.SubjectAccessReviewStatus{Allowed: true},
+		}, nil
+	})
+
+	// Stand in for the job controller by reporting a running pod for the
+	// job as soon as it is created
+	w := watch.NewFakeWithChanSize(1, false)
+	cli.PrependWatchReactor("pods", k8stesting.DefaultWatchReactor(w, nil))
+	cli.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		job := action.(k8stesting.CreateAction).GetObject().(*batchv1.Job)
+		w.Modify(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      job.Name + "-abcde",
+				Namespace: job.Namespace,
+				Labels:    map[string]string{"job-name": job.Name},
+			},
+			Spec: job.Spec.Template.Spec,
+			Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+			},
+		})
+		return false, nil, nil
+	})
+
+	cfg := &rest.Config{Host: "https://127.0.0.1:6443"}
+
+	require.Error(t, WithJob(-1)(&k8s{}))
+
+	k, err := NewFromConfig(cfg, WithJob(3))
+	require.NoError(t, err)
+	k.cli = cli
+	k.request.Name = "imagetest-job"
+
+	pod, err := k.setupPod(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "imagetest-job-abcde", pod.Name)
+
+	job, err := cli.BatchV1().Jobs("default").Get(ctx, "imagetest-job", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, int32(3), *job.Spec.BackoffLimit)
+	require.Equal(t, corev1.RestartPolicyNever, job.Spec.Template.Spec.RestartPolicy)
+	require.Equal(t, "sandbox", job.Spec.Template.Spec.Containers[0].Name)
+
+	// No bare pod is created, and the job's pods are watched
+	pods, err := cli.CoreV1().Pods("default").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Empty(t, pods.Items)
+
+	var watched bool
+	for _, action := range cli.Actions() {
+		if wa, ok := action.(k8stesting.WatchAction); ok && wa.GetResource().Resource == "pods" {
+			require.Equal(t, "job-name=imagetest-job", wa.GetWatchRestrictions().Labels.String())
+			watched = true
+		}
+	}
+	require.True(t, watched)
+
+	// Destroying the sandbox deletes the job
+	require.NoError(t, k.Destroy(ctx))
+	_, err = cli.BatchV1().Jobs("default").Get(ctx, "imagetest-job", metav1.GetOptions{})
+	require.Error(t, err)
+}
+
+func TestJobPodReplacement(t *testing.T) {
+	ctx := context.Background()
+	cfg := &rest.Config{Host: "https://127.0.0.1:6443"}
+
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "imagetest-job", Namespace: "default"}}
+	jobPod := func(name string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{"job-name": job.Name},
+			},
+			Spec:   corev1.PodSpec{Containers: []corev1.Container{{Name: "sandbox"}}},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+	}
+
+	// Stand in for the job controller, reporting whichever pod currently
+	// backs the job to each new watch
+	newSandbox := func(t *testing.T, job *batchv1.Job) (*k8s, *fake.Clientset, *[]string) {
+		original := jobPod("imagetest-job-abcde")
+		cli := fake.NewClientset(job, original)
+		current := original
+		cli.PrependWatchReactor("pods", func(k8stesting.Action) (bool, watch.Interface, error) {
+			w := watch.NewFakeWithChanSize(1, false)
+			w.Add(current)
+			return true, w, nil
+		})
+
+		k, err := NewFromConfig(cfg, WithJob(3))
+		require.NoError(t, err)
+		k.cli = cli
+		k.job = job
+		k.pod = original
+		k.usage = func(context.Context, *corev1.Pod) ([]ContainerUsage, error) {
+			return nil, nil
+		}
+
+		var execs []string
+		k.podExec = func(ctx context.Context, pod *corev1.Pod, _ harness.Command) error {
+			execs = append(execs, pod.Name)
+			if pod.Name != original.Name {
+				return nil
+			}
+
+			// The node running the pod is preempted, and the job replaces it
+			require.NoError(t, cli.Tracker().Delete(corev1.SchemeGroupVersion.WithResource("pods"), pod.Namespace, pod.Name))
+			current = jobPod("imagetest-job-fghij")
+			return fmt.Errorf("error dialing backend: pod not found")
+		}
+
+		return k, cli, &execs
+	}
+
+	t.Run("replaced", func(t *testing.T) {
+		k, _, execs := newSandbox(t, job.DeepCopy())
+
+		require.NoError(t, k.exec(ctx, harness.Command{Args: "true"}))
+		require.Equal(t, []string{"imagetest-job-abcde", "imagetest-job-fghij"}, *execs)
+		require.Equal(t, "imagetest-job-fghij", k.pod.Name)
+	})
+
+	t.Run("job failed", func(t *testing.T) {
+		failed := job.DeepCopy()
+		failed.Status.Conditions = []batchv1.JobCondition{{
+			Type:    batchv1.JobFailed,
+			Status:  corev1.ConditionTrue,
+			Reason:  "BackoffLimitExceeded",
+			Message: "Job has reached the specified backoff limit",
+		}}
+		k, _, execs := newSandbox(t, failed)
+
+		err := k.exec(ctx, harness.Command{Args: "true"})
+		require.ErrorContains(t, err, "pod not found")
+		require.ErrorContains(t, err, "BackoffLimitExceeded")
+		require.Equal(t, []string{"imagetest-job-abcde"}, *execs)
+	})
+
+	t.Run("bare pod", func(t *testing.T) {
+		k, _, execs := newSandbox(t, job.DeepCopy())
+		k.job = nil
+
+		require.ErrorContains(t, k.exec(ctx, harness.Command{Args: "true"}), "pod not found")
+		require.Equal(t, []string{"imagetest-job-abcde"}, *execs)
+	})
+}
+
+func TestLabels(t *testing.T) {
+	cfg := &rest.Config{Host: "https://127.0.0.1:6443"}
+
+	k, err := NewFromConfig(cfg)
+	require.NoError(t, err)
+
+	pod := k.podRequest("default", "sandbox")
+	require.Equal(t, map[string]string{"dev.chainguard.imagetest": "true"}, pod.Labels)
+	require.Empty(t, pod.Annotations)
+
+	k, err = NewFromConfig(cfg,
+		WithLabels(map[string]string{"team": "images", "test": "nginx"}),
+		WithAnnotations(map[string]string{"cost-center": "ci"}),
+	)
+	require.NoError(t, err)
+
+	pod = k.podRequest("default", "sandbox")
+	require.Equal(t, map[string]string{
+		"dev.chainguard.imagetest": "true",
+		"team":                     "images",
+		"test":                     "nginx",
+	}, pod.Labels)
+	require.Equal(t, map[string]string{"cost-center": "ci"}, pod.Annotations)
+
+	// Jobs carry the same metadata, on both the job and its pods
+	cli := fake.NewClientset()
+	k, err = NewFromConfig(cfg,
+		WithJob(0),
+		WithLabels(map[string]string{"team": "images"}),
+		WithAnnotations(map[string]string{"cost-center": "ci"}),
+	)
+	require.NoError(t, err)
+	k.cli = cli
+	k.request.Name = "imagetest-job"
+
+	job, err := k.createJob(context.Background(), k.podRequest("default", "sandbox"))
+	require.NoError(t, err)
+
+	labels := map[string]string{"dev.chainguard.imagetest": "true", "team": "images"}
+	annotations := map[string]string{"cost-center": "ci"}
+	require.Equal(t, labels, job.Labels)
+	require.Equal(t, annotations, job.Annotations)
+	require.Equal(t, labels, job.Spec.Template.Labels)
+	require.Equal(t, annotations, job.Spec.Template.Annotations)
+}
+
+func TestImagePullPolicy(t *testing.T) {
+	cfg := &rest.Config{Host: "https://127.0.0.1:6443"}
+
+	k, err := NewFromConfig(cfg, WithSidecar("db", "postgres", nil, nil))
+	require.NoError(t, err)
+
+	pod := k.podRequest("default", "sandbox")
+	for _, c := range pod.Spec.Containers {
+		require.Empty(t, c.ImagePullPolicy)
+	}
+
+	k, err = NewFromConfig(cfg,
+		WithSidecar("db", "postgres", nil, nil),
+		WithImagePullPolicy(corev1.PullIfNotPresent),
+	)
+	require.NoError(t, err)
+
+	pod = k.podRequest("default", "sandbox")
+	require.Len(t, pod.Spec.Containers, 2)
+	for _, c := range pod.Spec.Containers {
+		require.Equal(t, corev1.PullIfNotPresent, c.ImagePullPolicy, c.Name)
+	}
+
+	_, err = NewFromConfig(cfg, WithImagePullPolicy("Sometimes"))
+	require.Error(t, err)
+}
+
+func TestUsage(t *testing.T) {
+	ctx := context.Background()
+
+	metrics := `{"containers":[{"name":"sandbox","usage":{"cpu":"250m","memory":"512Mi"}},{"name":"db","usage":{"cpu":"10m","memory":"64Mi"}}]}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apis/metrics.k8s.io/v1beta1/namespaces/default/pods/sandbox" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(metrics))
+	}))
+	t.Cleanup(srv.Close)
+
+	k, err := NewFromConfig(&rest.Config{Host: srv.URL})
+	require.NoError(t, err)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "sandbox", Namespace: "default"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "sandbox"}}},
+	}
+
+	usage, err := k.podUsage(ctx, pod)
+	require.NoError(t, err)
+	require.Equal(t, "sandbox: cpu=250m memory=512Mi, db: cpu=10m memory=64Mi", formatUsage(usage))
+
+	// Without metrics-server there is no usage
+	_, err = k.podUsage(ctx, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"}})
+	require.Error(t, err)
+
+	// Failed steps report the usage, fetched within a deadline
+	k.pod = pod
+	k.podExec = func(context.Context, *corev1.Pod, harness.Command) error {
+		return fmt.Errorf("command terminated with exit code 137")
+	}
+	k.usage = func(ctx context.Context, p *corev1.Pod) ([]ContainerUsage, error) {
+		_, ok := ctx.Deadline()
+		require.True(t, ok)
+		return k.podUsage(ctx, p)
+	}
+	err = k.exec(ctx, harness.Command{Args: "true"})
+	require.EqualError(t, err, "command terminated with exit code 137 (sandbox usage: sandbox: cpu=250m memory=512Mi, db: cpu=10m memory=64Mi)")
+
+	// Missing metrics don't fail the step's error reporting or the teardown
+	k.pod = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"}}
+	k.usage = k.podUsage
+	require.EqualError(t, k.exec(ctx, harness.Command{Args: "true"}), "command terminated with exit code 137")
+	require.NoError(t, k.Destroy(ctx))
+}
+
+func TestNamespaceCleanup(t *testing.T) {
+	ctx := context.Background()
+	cfg := &rest.Config{Host: "https://127.0.0.1:6443"}
+
+	setup := func(t *testing.T, cli *fake.Clientset) *k8s {
+		t.Helper()
+
+		k, err := NewFromConfig(cfg)
+		require.NoError(t, err)
+		k.cli = cli
+		k.request.Name = "imagetest-ns"
+		k.usage = func(context.Context, *corev1.Pod) ([]ContainerUsage, error) {
+			return nil, fmt.Errorf("no metrics")
+		}
+
+		pod, err := k.setupPod(ctx)
+		require.NoError(t, err)
+		k.pod = pod
+		return k
+	}
+
+	t.Run("created", func(t *testing.T) {
+		cli := newFakeClientset(t)
+		k := setup(t, cli)
+
+		require.NoError(t, k.Destroy(ctx))
+		_, err := cli.CoreV1().Namespaces().Get(ctx, "default", metav1.GetOptions{})
+		require.Error(t, err)
+		_, err = cli.RbacV1().ClusterRoleBindings().Get(ctx, "imagetest-ns", metav1.GetOptions{})
+		require.Error(t, err)
+	})
+
+	t.Run("existing", func(t *testing.T) {
+		cli := newFakeClientset(t, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+		k := setup(t, cli)
+
+		require.NoError(t, k.Destroy(ctx))
+		_, err := cli.CoreV1().Namespaces().Get(ctx, "default", metav1.GetOptions{})
+		require.NoError(t, err)
+	})
+
+	t.Run("in use", func(t *testing.T) {
+		cli := newFakeClientset(t)
+		k := setup(t, cli)
+
+		// Another run started using the namespace
+		require.NoError(t, cli.Tracker().Add(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"},
+		}))
+
+		require.NoError(t, k.Destroy(ctx))
+		_, err := cli.CoreV1().Namespaces().Get(ctx, "default", metav1.GetOptions{})
+		require.NoError(t, err)
+	})
+}
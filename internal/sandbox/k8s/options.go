@@ -1,6 +1,12 @@
 package k8s
 
-import "github.com/google/go-containerregistry/pkg/name"
+import (
+	"fmt"
+	"sort"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	corev1 "k8s.io/api/core/v1"
+)
 
 type Option func(*k8s) error
 
@@ -31,3 +37,151 @@ func WithGracePeriod(gracePeriod int64) Option {
 		return nil
 	}
 }
+
+// WithWorkingDir sets the working directory of the sandbox container. An empty
+// dir keeps the image's working directory.
+func WithWorkingDir(dir string) Option {
+	return func(k *k8s) error {
+		if dir == "" {
+			return nil
+		}
+		k.request.WorkingDir = dir
+		return nil
+	}
+}
+
+// WithSidecar adds a companion container (a database, etc...) to the sandbox
+// pod. It shares the pod network, so the sandbox can reach it on localhost at
+// the given ports. Sidecars don't gate completion, the sandbox container
+// drives the run.
+func WithSidecar(name, image string, ports []int, env map[string]string) Option {
+	return func(k *k8s) error {
+		if name == "" || image == "" {
+			return fmt.Errorf("sidecar name and image are required")
+		}
+
+		if name == "sandbox" {
+			return fmt.Errorf("sidecar name %q is reserved", name)
+		}
+
+		c := corev1.Container{
+			Name:  name,
+			Image: image,
+		}
+
+		for _, p := range ports {
+			c.Ports = append(c.Ports, corev1.ContainerPort{
+				ContainerPort: int32(p),
+				Protocol:      corev1.ProtocolTCP,
+			})
+		}
+
+		// Sort the envs so the resulting pod spec is deterministic
+		keys := make([]string, 0, len(env))
+		for key := range env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			c.Env = append(c.Env, corev1.EnvVar{
+				Name:  key,
+				Value: env[key],
+			})
+		}
+
+		k.sidecars = append(k.sidecars, c)
+		return nil
+	}
+}
+
+// WithDNSPolicy sets the DNS policy of the sandbox pod.
+func WithDNSPolicy(policy corev1.DNSPolicy) Option {
+	return func(k *k8s) error {
+		k.dnsPolicy = policy
+		return nil
+	}
+}
+
+// WithDNSConfig sets the DNS config of the sandbox pod, such as custom
+// nameservers or ndots. When used with a "None" DNS policy, the config must
+// provide at least one nameserver.
+func WithDNSConfig(cfg *corev1.PodDNSConfig) Option {
+	return func(k *k8s) error {
+		k.dnsConfig = cfg
+		return nil
+	}
+}
+
+// WithServiceAccount runs the sandbox pod as a pre-existing service account
+// in the sandbox namespace, such as one annotated for workload identity,
+// instead of creating one bound to cluster-admin.
+func WithServiceAccount(name string) Option {
+	return func(k *k8s) error {
+		k.serviceAccount = name
+		return nil
+	}
+}
+
+// WithNodeName pins the sandbox pod to the node with the given name. This
+// bypasses the scheduler entirely, so the pod is placed even if the node is
+// cordoned, and never starts if the node does not exist.
+func WithNodeName(name string) Option {
+	return func(k *k8s) error {
+		k.nodeName = name
+		return nil
+	}
+}
+
+// WithJob runs the sandbox as a Job instead of a bare pod. If the sandbox pod
+// fails, such as when its node is preempted, the Job replaces it up to
+// backoffLimit times and the failed step is rerun in the replacement pod.
+func WithJob(backoffLimit int32) Option {
+	return func(k *k8s) error {
+		if backoffLimit < 0 {
+			return fmt.Errorf("backoff limit must not be negative: %d", backoffLimit)
+		}
+		k.backoffLimit = &backoffLimit
+		return nil
+	}
+}
+
+// WithLabels adds labels to the sandbox pod, such as for attributing pods to
+// tests for cost allocation or network policies. They are merged with the
+// dev.chainguard.imagetest label, which they may override.
+func WithLabels(labels map[string]string) Option {
+	return func(k *k8s) error {
+		for key, v := range labels {
+			k.request.Labels[key] = v
+		}
+		return nil
+	}
+}
+
+// WithAnnotations adds annotations to the sandbox pod.
+func WithAnnotations(annotations map[string]string) Option {
+	return func(k *k8s) error {
+		if k.annotations == nil {
+			k.annotations = make(map[string]string)
+		}
+		for key, v := range annotations {
+			k.annotations[key] = v
+		}
+		return nil
+	}
+}
+
+// WithImagePullPolicy sets the image pull policy of the sandbox and sidecar
+// containers. By default the cluster's policy applies, which always pulls
+// :latest tags.
+func WithImagePullPolicy(policy corev1.PullPolicy) Option {
+	return func(k *k8s) error {
+		switch policy {
+		case corev1.PullAlways, corev1.PullIfNotPresent, corev1.PullNever:
+		default:
+			return fmt.Errorf("invalid image pull policy: %q", policy)
+		}
+		k.pullPolicy = policy
+		return nil
+	}
+}
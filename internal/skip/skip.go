@@ -49,3 +49,42 @@ func shouldExclude(a, b map[string]string) (bool, string) {
 	}
 	return true, "skipped due to presence of excluded labels: " + strings.TrimSpace(reason)
 }
+
+// Labeled is anything with a name and a set of labels that can be evaluated
+// by Skip.
+type Labeled struct {
+	Name   string
+	Labels map[string]string
+}
+
+// Decision is the outcome of evaluating a single Labeled item.
+type Decision struct {
+	Name   string
+	Skip   bool
+	Reason string
+}
+
+// Plan is a dry run of Skip over a set of items. It returns a decision for
+// each item, in the same order as the items, describing whether the item would
+// run or be skipped and why. Nothing is executed; this is intended for
+// reporting what a set of include and exclude labels will select.
+func Plan(items []Labeled, include, exclude map[string]string) []Decision {
+	decisions := make([]Decision, 0, len(items))
+	for _, item := range items {
+		skip, reason := Skip(item.Labels, include, exclude)
+		if !skip {
+			if len(include) == 0 && len(exclude) == 0 {
+				reason = "running, no inclusion or exclusion labels provided"
+			} else {
+				reason = "running, labels match the inclusion and exclusion rules"
+			}
+		}
+
+		decisions = append(decisions, Decision{
+			Name:   item.Name,
+			Skip:   skip,
+			Reason: reason,
+		})
+	}
+	return decisions
+}
@@ -85,3 +85,62 @@ func TestSkip(t *testing.T) {
 		})
 	}
 }
+
+func TestPlan(t *testing.T) {
+	items := []Labeled{
+		{Name: "small", Labels: map[string]string{"size": "small"}},
+		{Name: "small-flaky", Labels: map[string]string{"size": "small", "flaky": "true"}},
+		{Name: "large", Labels: map[string]string{"size": "large"}},
+		{Name: "unlabeled"},
+	}
+
+	t.Run("no filtering", func(t *testing.T) {
+		decisions := Plan(items, nil, nil)
+		if len(decisions) != len(items) {
+			t.Fatalf("expected %d decisions, got %d", len(items), len(decisions))
+		}
+		for _, d := range decisions {
+			if d.Skip {
+				t.Errorf("expected %s to run, got skipped: %s", d.Name, d.Reason)
+			}
+			if !strings.Contains(d.Reason, "no inclusion or exclusion") {
+				t.Errorf("unexpected reason for %s: %s", d.Name, d.Reason)
+			}
+		}
+	})
+
+	t.Run("include and exclude", func(t *testing.T) {
+		decisions := Plan(items,
+			map[string]string{"size": "small"},
+			map[string]string{"flaky": "true"},
+		)
+
+		want := []struct {
+			name   string
+			skip   bool
+			reason string
+		}{
+			{name: "small", skip: false, reason: "running"},
+			{name: "small-flaky", skip: true, reason: "excluded labels: flaky=true"},
+			{name: "large", skip: true, reason: "missing required labels: size=small"},
+			{name: "unlabeled", skip: true, reason: "missing required labels: size=small"},
+		}
+
+		if len(decisions) != len(want) {
+			t.Fatalf("expected %d decisions, got %d", len(want), len(decisions))
+		}
+
+		for i, w := range want {
+			d := decisions[i]
+			if d.Name != w.name {
+				t.Errorf("expected decision %d to be for %s, got %s", i, w.name, d.Name)
+			}
+			if d.Skip != w.skip {
+				t.Errorf("expected %s skip: %t, got: %t", w.name, w.skip, d.Skip)
+			}
+			if !strings.Contains(d.Reason, w.reason) {
+				t.Errorf("expected reason for %s to contain '%s', got: %s", w.name, w.reason, d.Reason)
+			}
+		}
+	})
+}